@@ -1,6 +1,7 @@
 package dsearch
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -21,53 +23,191 @@ type model struct {
 	height int
 	width  int
 
+	ctx       context.Context
+	cfg       *Config
+	fzfCfg    FzfConfig
+	providers []Provider
+	matcher   string
+	history   IHistory
 	manager   IEntryManager
 	textInput textinput.Model
 
+	dmenu      bool
+	printQuery bool
+	prompt     string
+	output     *string
+
 	refreshCon SigRefresh
 	nodes      []EntryNode
 	cursor     int
+	menu       *actionMenu
+
+	previewID string
+	preview   string
+}
+
+type actionMenu struct {
+	node    EntryNode
+	actions []Action
+	cursor  int
+	input   *textinput.Model
+}
+
+type options struct {
+	debug      bool
+	dmenu      bool
+	printQuery bool
+	prompt     string
+	ignoreCase bool
 }
 
 type LoadedMsg struct{}
 type RefreshedMsg struct{ nodes []EntryNode }
 type SelectedMsg struct{ entry EntryNode }
 type QueryMsg struct{ query string }
+type ReloadedMsg struct{ done bool }
+type PreviewMsg struct {
+	id      string
+	content string
+}
+type ActionMsg struct {
+	entry  EntryNode
+	action Action
+	input  string
+}
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func isDebug() bool {
-	debugFlag := flag.Bool("DEBUG", false, "Debug Mode")
-	flag.Parse()
-	return *debugFlag || len(os.Getenv("DEBUG")) > 0
+func parseOptions(args []string) (*options, error) {
+	opts := &options{}
+	flags := flag.NewFlagSet("dsearch", flag.ContinueOnError)
+	flags.BoolVar(&opts.debug, "DEBUG", false, "Debug Mode")
+	flags.BoolVar(&opts.dmenu, "dmenu", false,
+		"Read candidates from stdin and print the selection")
+	flags.BoolVar(&opts.printQuery, "print-query", false,
+		"Print the query instead of the selection in dmenu mode")
+	flags.StringVar(&opts.prompt, "p", "", "Prompt in dmenu mode")
+	flags.BoolVar(&opts.ignoreCase, "i", false,
+		"Match case insensitively in dmenu mode")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.debug = opts.debug || len(os.Getenv("DEBUG")) > 0
+	return opts, nil
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
 func Run() {
-	if homeDir, err := os.UserHomeDir(); isDebug() && err == nil {
+	if code := run(); code != 0 {
+		os.Exit(code)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func setupLog(debug bool) (io.Closer, error) {
+	if homeDir, err := os.UserHomeDir(); debug && err == nil {
 		dir := fmt.Sprintf(`%s/.dsearch.log`, homeDir)
 		f, err := tea.LogToFile(dir, "dsearch")
 		if err != nil {
 			log.Printf(`Failed to log to file, err: %v`, err)
-			os.Exit(1)
+			return nil, err
+		}
+		return f, nil
+	}
+	log.SetOutput(io.Discard)
+	return io.NopCloser(nil), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func run() int {
+	if args := os.Args[1:]; len(args) > 0 && args[0] == filterCommand {
+		return runFilter(args[1:], os.Stdout)
+	}
+
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		return 2
+	}
+
+	f, err := setupLog(opts.debug)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := LoadConfig()
+	fzfCfg := cfg.Search.fzfConfig()
+	providers := NewProviders(cfg)
+	history := NewHistory(historyPath())
+	var programOpts []tea.ProgramOption
+	if opts.dmenu || !isTerminal(os.Stdout) {
+		// NOTE: stdin carries the candidates and stdout the selection, so the
+		// interface is drawn on the terminal directly. The same goes for the
+		// printed actions captured by a shell wrapper.
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			log.Printf(`Failed to open terminal, err: %v`, err)
+			return 1
 		}
-		defer f.Close()
-	} else {
-		log.SetOutput(io.Discard)
+		defer tty.Close()
+		programOpts = append(programOpts,
+			tea.WithInput(tty),
+			tea.WithOutput(tty))
+	}
+	if opts.dmenu {
+		fzfCfg.prefixes = nil
+		fzfCfg.ignoreCase = opts.ignoreCase
+		providers = []Provider{&dmenuProvider{reader: os.Stdin}}
+		history = NewHistory("")
 	}
 
-	fzfCfg := FzfConfig{exact: false, ignoreCase: true, algo: 0}
 	refreshSignal := make(SigRefresh)
+	manager := NewEntryManager(refreshSignal, fzfCfg, history, providers...)
+	if !opts.dmenu {
+		manager.SetIndex(indexPath())
+	}
 	p := tea.NewProgram(&model{
-		manager:    NewEntryManager(refreshSignal, fzfCfg),
+		ctx:        ctx,
+		cfg:        cfg,
+		fzfCfg:     fzfCfg,
+		providers:  providers,
+		matcher:    fzfCfg.matcher,
+		history:    history,
+		dmenu:      opts.dmenu,
+		printQuery: opts.printQuery,
+		prompt:     opts.prompt,
+		manager:    manager,
 		refreshCon: refreshSignal,
 		cursor:     0,
-	})
-	if _, err := p.Run(); err != nil {
+	}, programOpts...)
+	final, err := p.Run()
+	if err != nil {
 		log.Printf(`Alas, there's been an error: %v`, err)
-		os.Exit(1)
+		return 1
+	}
+
+	output := final.(*model).output
+	if output == nil {
+		if opts.dmenu {
+			return 1
+		}
+		return 0
 	}
+	fmt.Println(*output)
+	return 0
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -78,26 +218,108 @@ func (m *model) Init() tea.Cmd {
 		tea.SetWindowTitle("DSearch"),
 		textinput.Blink,
 		onViewRefreshed(m.refreshCon),
-		onLoadEntries(m.manager),
+		onLoadEntries(m.ctx, m.manager, m.providers),
 	)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func onLoadEntries(manager IEntryManager) tea.Cmd {
+func (m *model) record(entry EntryNode) {
+	id := entry.ID()
+	if len(id) == 0 {
+		return
+	}
+	_, query := m.fzfCfg.scope(m.textInput.Value())
+	if err := m.history.Record(id, query); err != nil {
+		log.Printf(`Failed to record %s, err: %v`, entry.Value(), err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func onLoadEntries(
+	ctx context.Context,
+	manager IEntryManager,
+	providers []Provider,
+) tea.Cmd {
 	return func() tea.Msg {
-		manager.LoadEntries(
-			func(c chan *Entry) { loadApplications(c) },
-			func(c chan *Entry) { loadFiles(c, true) })
+		updates := watchProviders(ctx, providers)
+		loadProviders(ctx, manager, providers)
+		go applyUpdates(ctx, manager, updates)
 		return LoadedMsg{}
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func watchProviders(
+	ctx context.Context,
+	providers []Provider,
+) <-chan EntryUpdate {
+	updates := make(chan EntryUpdate)
+	for _, provider := range providers {
+		if provider, ok := provider.(WatchProvider); ok {
+			provider.Watch(ctx, updates)
+		}
+	}
+	return updates
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func applyUpdates(
+	ctx context.Context,
+	manager IEntryManager,
+	updates <-chan EntryUpdate,
+) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-updates:
+			manager.UpdateEntries(ctx, update)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadProviders(
+	ctx context.Context,
+	manager IEntryManager,
+	providers []Provider,
+) {
+	var loaders []func(chan *Entry)
+	for _, provider := range providers {
+		loaders = append(loaders, func(c chan *Entry) {
+			provider.Load(ctx, c)
+		})
+	}
+	manager.LoadEntries(loaders...)
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err := manager.SaveIndex(); err != nil {
+		log.Printf(`Failed to save index, err: %v`, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	return model, tea.Batch(cmd, m.onPreview())
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (m *model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.menu != nil {
+			return m, m.onMenuKey(msg)
+		}
 		if cmd := m.onKeyChanged(msg.Type); cmd != nil {
 			return m, cmd
 		}
@@ -119,14 +341,53 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, onViewRefreshed(m.refreshCon)
 	case LoadedMsg:
 		log.Printf(`Finished to load all entries`)
-		return m, onViewRefreshed(m.refreshCon)
+		// NOTE: Entries restored from the index may have been removed while
+		// filtering, so the query is filtered again.
+		return m, tea.Batch(
+			onViewRefreshed(m.refreshCon),
+			m.onFilterRequested(m.textInput.Value()))
 	case QueryMsg:
 		return m, onFilterEntry(m.manager, msg.query)
+	case PreviewMsg:
+		if msg.id == m.previewID {
+			m.preview = msg.content
+		}
+		return m, nil
 	case SelectedMsg:
+		if m.dmenu {
+			return m, m.onOutput(msg.entry.Value())
+		}
+		log.Printf(`Select entry %s`, msg.entry.Value())
+		m.record(msg.entry)
+		provider := findProvider(m.providers, msg.entry.Source())
+		if provider, ok := provider.(SelectProvider); ok {
+			return m, onSelectProvider(m.ctx, m.manager, provider, msg.entry)
+		}
+		m.manager.Execute(msg.entry)
+		return m, tea.Quit
+	case ActionMsg:
 		name := msg.entry.Value()
-		log.Printf(`Select entry %s`, name)
-		msg.entry.Execute()
+		log.Printf(`Run action %s of %s`, msg.action.Name(), name)
+		m.record(msg.entry)
+		switch {
+		case len(msg.action.Output()) > 0:
+			return m, m.onOutput(msg.action.Output())
+		case msg.action.input != nil:
+			msg.action.input.submit(msg.input)
+		default:
+			msg.action.Execute()
+		}
 		return m, tea.Quit
+	case ReloadedMsg:
+		if msg.done {
+			return m, tea.Quit
+		}
+		query := m.textInput.Value()
+		_, rest := m.fzfCfg.scope(query)
+		query = strings.TrimSuffix(query, rest)
+		m.textInput.SetValue(query)
+		m.cursor = 0
+		return m, m.onFilterRequested(query)
 	default:
 		return m, nil
 	}
@@ -145,12 +406,62 @@ func onFilterEntry(manager IEntryManager, query string) tea.Cmd {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func onSelectProvider(
+	ctx context.Context,
+	manager IEntryManager,
+	provider SelectProvider,
+	entry EntryNode,
+) tea.Cmd {
+	return func() tea.Msg {
+		entries, done := provider.Select(ctx, entry)
+		if len(entries) > 0 {
+			manager.ReplaceEntries(provider.Name(), entries)
+		}
+		return ReloadedMsg{done: done}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func (m *model) updateCursor() {
 	m.cursor = max(min(m.cursor, len(m.nodes)-1), 0)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func (m *model) previewing() bool {
+	return m.cfg != nil && m.cfg.Preview.Enabled && !m.dmenu
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (m *model) onPreview() tea.Cmd {
+	if !m.previewing() {
+		return nil
+	}
+	var node EntryNode
+	if m.cursor < len(m.nodes) {
+		node = m.nodes[m.cursor]
+	}
+	id := ""
+	if node != nil {
+		id = node.Source() + ":" + node.ID()
+	}
+	if id == m.previewID {
+		return nil
+	}
+	m.previewID, m.preview = id, ""
+	if node == nil {
+		return nil
+	}
+	cfg := m.cfg.Preview
+	return func() tea.Msg {
+		return PreviewMsg{id: id, content: buildPreview(node, cfg)}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func onViewRefreshed(sigRefreshed chan RefreshedMsg) tea.Cmd {
 	return func() tea.Msg {
 		return RefreshedMsg(<-sigRefreshed)
@@ -166,6 +477,9 @@ func (m *model) onWindowReady() {
 	ti.CharLimit = 256
 	ti.Width = m.width
 	ti.Prompt = " "
+	if m.dmenu && len(m.prompt) > 0 {
+		ti.Prompt = m.prompt + " "
+	}
 	ti.KeyMap.WordForward = key.NewBinding(key.WithKeys("ctrl+right"))
 	ti.KeyMap.DeleteWordForward = key.NewBinding(key.WithKeys("\x1b[3;5~"))
 	ti.KeyMap.WordBackward = key.NewBinding(key.WithKeys("ctrl+left"))
@@ -205,16 +519,138 @@ func (m *model) onKeyChanged(key tea.KeyType) tea.Cmd {
 		m.cursor++
 		m.cursor = min(m.cursor, len(m.nodes)-1)
 	case tea.KeyEnter:
-		return onSelectedEntry(m.nodes[m.cursor])
+		return m.onEnter()
+	case tea.KeyCtrlX:
+		return m.onForgetEntry()
+	case tea.KeyCtrlT:
+		return m.onSwitchMatcher()
+	case tea.KeyTab, tea.KeyCtrlO:
+		return m.onOpenMenu()
+	default:
+	}
+	return nil
+}
+
+func (m *model) onOpenMenu() tea.Cmd {
+	if m.dmenu || m.cursor >= len(m.nodes) {
+		return nil
+	}
+	node := m.nodes[m.cursor]
+	m.menu = &actionMenu{node: node, actions: m.manager.Actions(node)}
+	return nil
+}
+
+func (m *model) onMenuKey(msg tea.KeyMsg) tea.Cmd {
+	menu := m.menu
+	if menu.input != nil {
+		return m.onMenuInput(msg)
+	}
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEsc, tea.KeyTab, tea.KeyCtrlO:
+		m.menu = nil
+	case tea.KeyUp, tea.KeyCtrlP:
+		menu.cursor = max(menu.cursor-1, 0)
+	case tea.KeyDown, tea.KeyCtrlN:
+		menu.cursor = max(min(menu.cursor+1, len(menu.actions)-1), 0)
+	case tea.KeyEnter:
+		if menu.cursor >= len(menu.actions) {
+			return nil
+		}
+		action := menu.actions[menu.cursor]
+		if action.input == nil {
+			return onSelectedAction(menu.node, action, "")
+		}
+		ti := textinput.New()
+		ti.Prompt = " " + action.input.prompt + ": "
+		ti.Width = m.width
+		ti.SetValue(action.input.value)
+		menu.input = &ti
+		return ti.Focus()
 	default:
 	}
 	return nil
 }
 
+func (m *model) onMenuInput(msg tea.KeyMsg) tea.Cmd {
+	menu := m.menu
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEsc:
+		menu.input = nil
+		return nil
+	case tea.KeyEnter:
+		action := menu.actions[menu.cursor]
+		return onSelectedAction(menu.node, action, menu.input.Value())
+	}
+	var cmd tea.Cmd
+	*menu.input, cmd = menu.input.Update(msg)
+	return cmd
+}
+
+func (m *model) onEnter() tea.Cmd {
+	if m.dmenu && (m.printQuery || len(m.nodes) == 0) {
+		return m.onOutput(m.textInput.Value())
+	}
+	if len(m.nodes) == 0 {
+		return m.onCustomInput()
+	}
+	if m.cursor >= len(m.nodes) {
+		return nil
+	}
+	return onSelectedEntry(m.nodes[m.cursor])
+}
+
+func (m *model) onCustomInput() tea.Cmd {
+	scope, text := m.fzfCfg.scope(m.textInput.Value())
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return nil
+	}
+	if _, ok := findProvider(m.providers, scope).(SelectProvider); !ok {
+		return nil
+	}
+	return onSelectedEntry(&Entry{source: scope, name: text})
+}
+
+func (m *model) onOutput(output string) tea.Cmd {
+	m.output = &output
+	return tea.Quit
+}
+
+func (m *model) onSwitchMatcher() tea.Cmd {
+	cfg := m.fzfCfg
+	cfg.matcher = nextMatcher(m.matcher)
+	matcher := NewMatcher(cfg)
+	m.matcher = matcher.Name()
+	log.Printf(`Switch to %s matcher`, m.matcher)
+	m.manager.SetMatcher(matcher)
+	return m.onFilterRequested(m.textInput.Value())
+}
+
+func (m *model) onForgetEntry() tea.Cmd {
+	if m.cursor >= len(m.nodes) {
+		return nil
+	}
+	entry := m.nodes[m.cursor]
+	if err := m.history.Forget(entry.ID()); err != nil {
+		log.Printf(`Failed to forget %s, err: %v`, entry.Value(), err)
+	}
+	return m.onFilterRequested(m.textInput.Value())
+}
+
 func onSelectedEntry(entry EntryNode) tea.Cmd {
 	return func() tea.Msg { return SelectedMsg{entry: entry} }
 }
 
+func onSelectedAction(entry EntryNode, action Action, input string) tea.Cmd {
+	return func() tea.Msg {
+		return ActionMsg{entry: entry, action: action, input: input}
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 func (m *model) View() string {
@@ -222,27 +658,77 @@ func (m *model) View() string {
 		return "\n Inializing ..."
 	}
 
+	if m.menu != nil {
+		return m.viewMenu()
+	}
+
 	sb := new(strings.Builder)
 
-	sb.WriteString(fmt.Sprintf("\n %s\n", m.textInput.View()))
+	scope, _ := m.fzfCfg.scope(m.textInput.Value())
+	sb.WriteString(fmt.Sprintf(
+		"\n %s%s\n",
+		renderScope(scope),
+		m.textInput.View()))
 
 	limit := m.height - 6
 	start := max(0, m.cursor+1-limit)
 	end := max(limit, m.cursor+1)
+	width := m.width
+	if m.previewing() {
+		width = m.width * (100 - min(max(m.cfg.Preview.Width, 10), 90)) / 100
+	}
 
+	var rows []string
 	for i := start; i < len(m.nodes) && i < end; i++ {
 		cursor := " "
 		if m.cursor == i {
 			cursor = ">"
 		}
-		sb.WriteString(fmt.Sprintf(
-			"\n %s %s",
+		rows = append(rows, fmt.Sprintf(
+			" %s %s",
 			cursor,
-			m.nodes[i].Value()))
+			renderEntry(m.nodes[i], width-4)))
+	}
+	list := strings.Join(rows, "\n")
+	if m.previewing() {
+		list = lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			lipgloss.NewStyle().Width(width).Render(list),
+			renderPreview(m.preview, m.width-width, limit))
 	}
+	if len(list) > 0 {
+		sb.WriteString("\n" + list)
+	}
+
+	sb.WriteString(fmt.Sprintf(
+		"\n\n Matcher: %s (Ctrl+T to switch). Tab for actions, Esc to quit.\n",
+		m.matcher))
+
+	return sb.String()
+}
 
-	sb.WriteString("\n\n Press Esc to quit.\n")
+func (m *model) viewMenu() string {
+	menu := m.menu
+	sb := new(strings.Builder)
+	sb.WriteString(fmt.Sprintf("\n %s\n", renderEntry(menu.node, m.width-2)))
+
+	if menu.input != nil {
+		sb.WriteString(fmt.Sprintf("\n%s\n", menu.input.View()))
+		sb.WriteString("\n Press Enter to confirm, Esc to go back.\n")
+		return sb.String()
+	}
+
+	limit := m.height - 6
+	start := max(0, menu.cursor+1-limit)
+	for i := start; i < len(menu.actions) && i < start+limit; i++ {
+		cursor := " "
+		if menu.cursor == i {
+			cursor = ">"
+		}
+		sb.WriteString(fmt.Sprintf("\n %s %s", cursor, menu.actions[i].Name()))
+	}
 
+	sb.WriteString("\n\n Press Enter to run the action, Esc to go back.\n")
 	return sb.String()
 }
 
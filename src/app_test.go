@@ -0,0 +1,147 @@
+package dsearch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func pressKeys(m *model, keys ...tea.KeyType) {
+	for _, key := range keys {
+		cmd := m.onMenuKey(tea.KeyMsg{Type: key})
+		if cmd == nil {
+			continue
+		}
+		if msg, ok := cmd().(ActionMsg); ok {
+			m.Update(msg)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestActionMenu(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"project/a.txt": ""})
+	project := filepath.Join(dir, "project")
+
+	providers := []Provider{&fileProvider{}}
+	newModel := func(node EntryNode) *model {
+		m := &model{
+			history: NewHistory(""),
+			manager: NewEntryManager(nil, dummyCfg, nil, providers...),
+			nodes:   []EntryNode{node},
+		}
+		m.onWindowReady()
+		if cmd := m.onKeyChanged(tea.KeyTab); cmd != nil || m.menu == nil {
+			t.Fatal(`Expected the action menu to open`)
+		}
+		return m
+	}
+
+	m := newModel(buildDirEntry(project))
+	pressKeys(m, tea.KeyDown, tea.KeyDown, tea.KeyDown, tea.KeyUp, tea.KeyEnter)
+	if m.output == nil || *m.output != project {
+		t.Errorf(`Expected %s got %v`, project, m.output)
+	}
+
+	m = newModel(buildFileEntry(filepath.Join(project, "a.txt")))
+	pressKeys(m, tea.KeyEsc)
+	if m.menu != nil {
+		t.Errorf(`Expected the action menu to close`)
+	}
+	m.onKeyChanged(tea.KeyCtrlO)
+	pressKeys(m, tea.KeyDown, tea.KeyDown, tea.KeyDown, tea.KeyDown, tea.KeyEnter)
+	if m.menu.input == nil || m.menu.input.Value() != "a.txt" {
+		t.Fatalf(`Expected to be asked for the new name`)
+	}
+	m.menu.input.SetValue("b.txt")
+	pressKeys(m, tea.KeyEnter)
+	if _, err := os.Stat(filepath.Join(project, "b.txt")); err != nil {
+		t.Errorf(`Expected a.txt to be renamed, err: %v`, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestPreviewPane(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Preview.Enabled = true
+	m := &model{cfg: cfg, height: 20, width: 80, ready: true}
+	m.onWindowReady()
+
+	a, b := loadCalculator("1+1"), loadCalculator("2+2")
+	_, cmd := m.Update(RefreshedMsg{nodes: []EntryNode{a, b}})
+	if cmd == nil {
+		t.Fatal(`Expected the preview to be requested`)
+	}
+	stale := PreviewMsg{id: m.previewID, content: buildPreview(a, cfg.Preview)}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(stale)
+	if len(m.preview) > 0 {
+		t.Errorf(`Expected the preview of %s to be dropped`, a.Value())
+	}
+
+	if cmd == nil {
+		t.Fatal(`Expected the preview to be requested`)
+	}
+	var msg tea.Msg = cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			if preview, ok := cmd().(PreviewMsg); ok {
+				msg = preview
+			}
+		}
+	}
+	m.Update(msg)
+	if !strings.HasPrefix(m.preview, "Decimal: 4\n") {
+		t.Errorf(`Expected the preview of %s got %q`, b.Value(), m.preview)
+	}
+	if view := m.View(); !strings.Contains(view, "│ Decimal: 4") {
+		t.Errorf(`Expected the preview pane in %q`, view)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestRecordScopedQuery(t *testing.T) {
+	cfg := DefaultConfig()
+	fzfCfg := cfg.Search.fzfConfig()
+	fzfCfg.matcher = MatcherFuzzy
+	history := NewHistory("")
+	m := &model{
+		fzfCfg:    fzfCfg,
+		history:   history,
+		manager:   NewEntryManager(nil, fzfCfg, history, NewProviders(cfg)...),
+		textInput: textinput.New(),
+	}
+	m.manager.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{id: "ffmpeg", name: "ffmpeg", source: SourceApplications}
+		entryChan <- &Entry{id: "firefox", name: "Firefox", source: SourceApplications}
+	})
+
+	nodes := m.manager.FilterEntry("a ff")
+	expected := []string{"ffmpeg", "Firefox"}
+	if result := extract(nodes); !slices.Equal(expected, result) {
+		t.Fatalf(`Expected %v got %v`, expected, result)
+	}
+	history.Record("ffmpeg", "")
+	history.Record("ffmpeg", "")
+	m.textInput.SetValue("a ff")
+	m.Update(ActionMsg{entry: nodes[1], action: Action{name: "Print", output: "x"}})
+
+	expected = []string{"Firefox", "ffmpeg"}
+	if result := extract(m.manager.FilterEntry("a ff")); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,154 @@
+package dsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type Config struct {
+	Launcher  LauncherConfig  `json:"launcher"`
+	Search    SearchConfig    `json:"search"`
+	Files     FilesConfig     `json:"files"`
+	Preview   PreviewConfig   `json:"preview"`
+	Providers map[string]bool `json:"providers"`
+	Scripts   []ScriptConfig  `json:"scripts"`
+}
+
+type LauncherConfig struct {
+	Backend  string   `json:"backend"`
+	Terminal []string `json:"terminal"`
+}
+
+type SearchConfig struct {
+	Matcher    string            `json:"matcher"`
+	Extended   bool              `json:"extended"`
+	Exact      bool              `json:"exact"`
+	IgnoreCase bool              `json:"ignore_case"`
+	Algo       string            `json:"algo"`
+	Sort       string            `json:"sort"`
+	Priority   []string          `json:"priority"`
+	Prefixes   map[string]string `json:"prefixes"`
+	WebSearch  string            `json:"web_search"`
+}
+
+type PreviewConfig struct {
+	Enabled  bool `json:"enabled"`
+	Width    int  `json:"width"`
+	MaxBytes int  `json:"max_bytes"`
+}
+
+const (
+	LauncherNative = "native"
+	LauncherGio    = "gio"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func DefaultConfig() *Config {
+	terminal := []string{"xterm", "-e"}
+	if term := os.Getenv("TERMINAL"); len(term) > 0 {
+		terminal = []string{term, "-e"}
+	}
+	return &Config{
+		Launcher: LauncherConfig{
+			Backend:  LauncherNative,
+			Terminal: terminal,
+		},
+		Search: SearchConfig{
+			Extended:   true,
+			Exact:      false,
+			IgnoreCase: true,
+			Algo:       "v2",
+			Sort:       "score",
+			Priority: []string{
+				SourceCalculator,
+				SourceApplications,
+				SourceFiles,
+			},
+			Prefixes: map[string]string{
+				"a ": SourceApplications,
+				"f ": SourceFiles,
+				"=":  SourceCalculator,
+				">":  SourceCommand,
+				"?":  SourceWeb,
+			},
+			WebSearch: "https://duckduckgo.com/?q=%s",
+		},
+		Files: FilesConfig{
+			Roots: []RootConfig{{
+				Path:    "~",
+				Exclude: []string{"node_modules/", "go/pkg/mod/"},
+			}},
+		},
+		Preview: PreviewConfig{
+			Enabled:  false,
+			Width:    50,
+			MaxBytes: 64 << 10,
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p SearchConfig) fzfConfig() FzfConfig {
+	cfg := FzfConfig{
+		matcher:    p.Matcher,
+		extended:   p.Extended,
+		ignoreCase: p.IgnoreCase,
+		priority:   p.Priority,
+		prefixes:   p.Prefixes,
+	}
+	if len(cfg.matcher) == 0 {
+		cfg.matcher = MatcherFuzzy
+		if p.Exact {
+			cfg.matcher = MatcherExact
+		}
+	}
+	if p.Algo == "v1" {
+		cfg.algo = 1
+	}
+	if p.Sort == "index" {
+		cfg.sort = SortIndex
+	}
+	return cfg
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func configPath() string {
+	return filepath.Join(
+		xdgDir("XDG_CONFIG_HOME", ".config"),
+		"dsearch",
+		"config.json")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	path := configPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg
+	} else if err != nil {
+		log.Printf(`Failed to read config %s, err: %v`, path, err)
+		return cfg
+	}
+	cfg.Files.Roots = nil
+	if err := json.Unmarshal(data, cfg); err != nil {
+		log.Printf(`Failed to parse config %s, err: %v`, path, err)
+		return DefaultConfig()
+	}
+	if cfg.Files.Roots == nil {
+		cfg.Files.Roots = DefaultConfig().Files.Roots
+	}
+	return cfg
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,39 @@
+package dsearch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestLoadConfigRoots(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "dsearch"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(config string) {
+		if err := os.WriteFile(configPath(), []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"files": {"roots": [{"path": "/srv"}]}}`)
+	roots := LoadConfig().Files.Roots
+	if len(roots) != 1 || roots[0].Path != "/srv" || roots[0].Exclude != nil {
+		t.Errorf(`Unexpected roots %+v`, roots)
+	}
+
+	write(`{"preview": {"enabled": true}}`)
+	roots = LoadConfig().Files.Roots
+	if expected := DefaultConfig().Files.Roots; len(roots) != 1 ||
+		roots[0].Path != expected[0].Path ||
+		!slices.Equal(expected[0].Exclude, roots[0].Exclude) {
+		t.Errorf(`Expected %+v got %+v`, expected, roots)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
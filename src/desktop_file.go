@@ -0,0 +1,290 @@
+package dsearch
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"code.rocketnine.space/tslocum/desktop"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	desktopEntryGroup  = "Desktop Entry"
+	desktopActionGroup = "Desktop Action "
+)
+
+type desktopGroup map[string]string
+
+type desktopFile struct {
+	path   string
+	groups map[string]desktopGroup
+}
+
+type desktopAction struct {
+	id   string
+	name string
+	icon string
+	exec string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func currentDesktops() []string {
+	var desktops []string
+	for _, name := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			desktops = append(desktops, name)
+		}
+	}
+	return desktops
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func desktopFileID(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func readDesktopFile(path string) (*desktopFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups, err := parseDesktopGroups(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := groups[desktopEntryGroup]; !ok {
+		return nil, errors.New(`section header not found`)
+	}
+	return &desktopFile{path: path, groups: groups}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseDesktopGroups(r io.Reader) (map[string]desktopGroup, error) {
+	groups := make(map[string]desktopGroup)
+	var current desktopGroup
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			name := line[1 : len(line)-1]
+			if _, ok := groups[name]; ok {
+				current = nil
+				continue
+			}
+			current = make(desktopGroup)
+			groups[name] = current
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, ok := current[key]; !ok {
+			current[key] = strings.TrimSpace(value)
+		}
+	}
+	return groups, scanner.Err()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func unescapeDesktopValue(value string) string {
+	if !strings.ContainsRune(value, '\\') {
+		return value
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 >= len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 's':
+			sb.WriteByte(' ')
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '\\':
+			sb.WriteByte('\\')
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(value[i])
+		}
+	}
+	return sb.String()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (g desktopGroup) str(key string) string {
+	return unescapeDesktopValue(g[key])
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (g desktopGroup) boolean(key string) bool {
+	return g[key] == "true"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (g desktopGroup) list(key string) []string {
+	var items []string
+	var sb strings.Builder
+	value := g[key]
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == ';':
+			i++
+			sb.WriteByte(';')
+		case value[i] == ';':
+			if sb.Len() > 0 {
+				items = append(items, unescapeDesktopValue(sb.String()))
+			}
+			sb.Reset()
+		default:
+			sb.WriteByte(value[i])
+		}
+	}
+	if sb.Len() > 0 {
+		items = append(items, unescapeDesktopValue(sb.String()))
+	}
+	return items
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) entry() *desktop.Entry {
+	group := f.groups[desktopEntryGroup]
+	entry := &desktop.Entry{
+		Name:        group.str("Name"),
+		GenericName: group.str("GenericName"),
+		Comment:     group.str("Comment"),
+		Icon:        group.str("Icon"),
+		Path:        group.str("Path"),
+		Exec:        group.str("Exec"),
+		URL:         group.str("URL"),
+		Terminal:    group.boolean("Terminal"),
+	}
+	switch strings.ToLower(group["Type"]) {
+	case "application":
+		entry.Type = desktop.Application
+	case "link":
+		entry.Type = desktop.Link
+	case "directory":
+		entry.Type = desktop.Directory
+	}
+	return entry
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) visible(desktops []string) bool {
+	return !f.groups[desktopEntryGroup].boolean("NoDisplay") &&
+		f.available(desktops)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) available(desktops []string) bool {
+	group := f.groups[desktopEntryGroup]
+	if group.boolean("Hidden") {
+		return false
+	}
+
+	inDesktops := func(names []string) bool {
+		return slices.ContainsFunc(names, func(name string) bool {
+			return slices.Contains(desktops, name)
+		})
+	}
+	if onlyShowIn := group.list("OnlyShowIn"); len(onlyShowIn) > 0 &&
+		!inDesktops(onlyShowIn) {
+		return false
+	}
+	if inDesktops(group.list("NotShowIn")) {
+		return false
+	}
+
+	if tryExec := group.str("TryExec"); len(tryExec) > 0 {
+		if _, err := exec.LookPath(tryExec); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) keywords() []string {
+	group := f.groups[desktopEntryGroup]
+	var keywords []string
+	if genericName := group.str("GenericName"); len(genericName) > 0 {
+		keywords = append(keywords, genericName)
+	}
+	keywords = append(keywords, group.list("Keywords")...)
+	if comment := group.str("Comment"); len(comment) > 0 {
+		keywords = append(keywords, comment)
+	}
+	if argv, err := splitExec(group.str("Exec")); err == nil && len(argv) > 0 {
+		keywords = append(keywords, filepath.Base(argv[0]))
+	}
+	return keywords
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) actions() []desktopAction {
+	var actions []desktopAction
+	for _, id := range f.groups[desktopEntryGroup].list("Actions") {
+		group, ok := f.groups[desktopActionGroup+id]
+		if !ok || len(group["Name"]) == 0 {
+			continue
+		}
+		actions = append(actions, desktopAction{
+			id:   id,
+			name: group.str("Name"),
+			icon: group.str("Icon"),
+			exec: group.str("Exec"),
+		})
+	}
+	return actions
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (f *desktopFile) mimeTypes() []string {
+	return f.groups[desktopEntryGroup].list("MimeType")
+}
+
+///////////////////////////////////////////////////////////////////////////////
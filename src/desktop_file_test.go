@@ -0,0 +1,61 @@
+package dsearch
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const firefoxDesktopFile = `
+[Desktop Entry]
+Type=Application
+Name=Firefox
+Name[de]=Firefox Webbrowser
+GenericName=Web Browser
+Keywords=Internet;WWW;Browser\;Web;
+Exec=firefox %u
+Actions=new-window;new-private-window;missing;
+
+# Comment
+[Desktop Action new-window]
+Name=New Window
+Exec=firefox --new-window %u
+
+[Desktop Action new-private-window]
+Name=New\sPrivate Window
+Exec=firefox --private-window %u
+`
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestParseDesktopGroups(t *testing.T) {
+	groups, err := parseDesktopGroups(strings.NewReader(firefoxDesktopFile))
+	if err != nil {
+		t.Fatalf(`Unexpected err %v`, err)
+	}
+	file := desktopFile{path: "firefox.desktop", groups: groups}
+
+	entry := file.entry()
+	if entry == nil || entry.Name != "Firefox" || entry.Exec != "firefox %u" {
+		t.Fatalf(`Unexpected entry %#v`, entry)
+	}
+
+	expected := []string{"Internet", "WWW", "Browser;Web"}
+	result := groups[desktopEntryGroup].list("Keywords")
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	var names []string
+	for _, action := range file.actions() {
+		names = append(names, action.name)
+	}
+	expected = []string{"New Window", "New Private Window"}
+	if !slices.Equal(expected, names) {
+		t.Errorf(`Expected %v got %v`, expected, names)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
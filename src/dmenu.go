@@ -0,0 +1,52 @@
+package dsearch
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type dmenuProvider struct {
+	reader io.Reader
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *dmenuProvider) Name() string {
+	return SourceDmenu
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *dmenuProvider) Load(ctx context.Context, sink chan<- *Entry) {
+	scanner := bufio.NewScanner(p.reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for i := 0; scanner.Scan(); i++ {
+		if ctx.Err() != nil {
+			return
+		}
+		if len(scanner.Text()) == 0 {
+			continue
+		}
+		sink <- &Entry{
+			id:     strconv.Itoa(i),
+			source: SourceDmenu,
+			name:   scanner.Text(),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf(`Failed to read candidates, err: %v`, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *dmenuProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Select")
+}
+
+///////////////////////////////////////////////////////////////////////////////
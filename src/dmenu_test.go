@@ -0,0 +1,100 @@
+package dsearch
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestDmenuProvider(t *testing.T) {
+	provider := &dmenuProvider{reader: strings.NewReader("one\n\ntwo\none\n")}
+	m := NewEntryManager(nil, dummyCfg, nil, provider)
+	m.LoadEntries(func(c chan *Entry) {
+		provider.Load(context.Background(), c)
+	})
+
+	result := extract(m.FilterEntry(""))
+	expected := []string{"one", "two", "one"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions([]string{"-dmenu", "-p", "run:", "-i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.dmenu || opts.prompt != "run:" || !opts.ignoreCase ||
+		opts.printQuery {
+		t.Errorf(`Unexpected options %+v`, opts)
+	}
+	if _, err := parseOptions([]string{"-unknown"}); err == nil {
+		t.Errorf(`Expected an error for an unknown flag`)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestDmenuOutput(t *testing.T) {
+	entry := &Entry{id: "0", name: "two"}
+	tests := []struct {
+		printQuery bool
+		nodes      []EntryNode
+		expected   string
+	}{
+		{false, []EntryNode{entry}, "two"},
+		{false, nil, "tw"},
+		{true, []EntryNode{entry}, "tw"},
+	}
+	for _, test := range tests {
+		m := &model{dmenu: true, printQuery: test.printQuery, nodes: test.nodes}
+		m.onWindowReady()
+		m.textInput.SetValue("tw")
+
+		cmd := m.onEnter()
+		if msg, ok := cmd().(SelectedMsg); ok {
+			_, cmd = m.Update(msg)
+			cmd()
+		}
+		if m.output == nil || *m.output != test.expected {
+			t.Errorf(`Expected %s got %v`, test.expected, m.output)
+		}
+	}
+
+	m := &model{}
+	m.onWindowReady()
+	if cmd := m.onEnter(); cmd != nil {
+		t.Errorf(`Expected nothing to select got %v`, cmd())
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestDmenuPrompt(t *testing.T) {
+	m := &model{}
+	m.onWindowReady()
+	defaults := m.textInput.Prompt
+
+	tests := []struct {
+		m        *model
+		expected string
+	}{
+		{&model{dmenu: true, prompt: "run:"}, "run: "},
+		{&model{dmenu: true}, defaults},
+		{&model{prompt: "run:"}, defaults},
+	}
+	for _, test := range tests {
+		test.m.onWindowReady()
+		if test.m.textInput.Prompt != test.expected {
+			t.Errorf(`Expected %q got %q`, test.expected, test.m.textInput.Prompt)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -6,21 +6,69 @@ import (
 	"log"
 	"runtime/debug"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/junegunn/fzf/src/util"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
 
 type Entry struct {
-	name    string
-	execute func()
+	id       string
+	source   string
+	name     string
+	subtitle string
+	icon     string
+	path     string
+	keywords []string
+	execute  func()
 }
 
 type EntryNode interface {
+	ID() string
+	Source() string
 	Value() string
+	Subtitle() string
+	Icon() string
+	Path() string
+	Keywords() []string
 	Execute()
 }
 
+type MatchedEntry struct {
+	EntryNode
+	positions []int
+	score     int
+}
+
+const keywordSeparator = "\t"
+
+const (
+	SourceCalculator   = "calculator"
+	SourceApplications = "applications"
+	SourceFiles        = "files"
+	SourceCommand      = "command"
+	SourceWeb          = "web"
+	SourceDmenu        = "dmenu"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *Entry) ID() string {
+	if len(p.id) == 0 {
+		return p.name
+	}
+	return p.id
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *Entry) Source() string {
+	return p.source
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 func (p *Entry) Value() string {
@@ -29,6 +77,52 @@ func (p *Entry) Value() string {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func (p *Entry) Subtitle() string {
+	return p.subtitle
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *Entry) Icon() string {
+	return p.icon
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *Entry) Path() string {
+	return p.path
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *Entry) Keywords() []string {
+	return p.keywords
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *MatchedEntry) Positions() []int {
+	return p.positions
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *MatchedEntry) Score() int {
+	return p.score
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func searchText(node EntryNode) string {
+	keywords := node.Keywords()
+	if len(keywords) == 0 {
+		return node.Value()
+	}
+	return node.Value() + keywordSeparator + strings.Join(keywords, " ")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func (p *Entry) Execute() {
 	if p.execute != nil {
 		p.execute()
@@ -39,20 +133,36 @@ func (p *Entry) Execute() {
 
 type hasher func(string) uint32
 
+type entryItem struct {
+	node    EntryNode
+	name    util.Chars
+	text    util.Chars
+	removed atomic.Bool
+}
+
 type IEntryHashTable interface {
-	traverse(start int, callback func(string) bool)
-	forEach(start, end int, callback func(string) bool)
-	transform(strs []string) []EntryNode
+	traverse(start int, callback func(int, *entryItem) bool) int
+	forEach(start, end int, callback func(int, *entryItem) bool)
+	transform(indexes []int) []EntryNode
+	lookup(id string) (int, bool)
+	get(index int) (EntryNode, bool)
 	getRawData() []EntryNode
 	emplace(e *Entry)
+	replace(e *Entry)
+	grow(n int)
+	removeSource(source string) int
+	remove(ids ...string) int
+	compact() bool
 	len() int
 }
 
 type EntryHashTable struct {
-	mutex sync.Mutex
-	array []EntryNode
-	hash  hasher
-	table map[uint32][]int
+	mutex   sync.Mutex
+	array   []EntryNode
+	items   []*entryItem
+	removed int
+	hash    hasher
+	table   map[uint32][]int
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -87,7 +197,17 @@ func (p *EntryHashTable) len() int {
 func (p *EntryHashTable) getRawData() []EntryNode {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	return p.array
+	if p.removed == 0 {
+		return p.array
+	}
+
+	nodes := make([]EntryNode, 0, len(p.array)-p.removed)
+	for i, node := range p.array {
+		if !p.items[i].removed.Load() {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -95,44 +215,199 @@ func (p *EntryHashTable) getRawData() []EntryNode {
 func (p *EntryHashTable) emplace(e *Entry) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	p.insert(e)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) replace(e *Entry) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	id := e.ID()
+	for _, i := range p.table[p.hash(id)] {
+		if p.array[i].ID() == id {
+			if sameEntry(p.array[i], e) {
+				return
+			}
+			p.drop(i)
+			break
+		}
+	}
+	p.insert(e)
+}
+
+///////////////////////////////////////////////////////////////////////////////
 
+func (p *EntryHashTable) insert(e *Entry) {
 	if e == nil {
 		debug.PrintStack()
 		log.Fatalf(`Cannot emplace nil entry`)
 	}
 
-	key := p.hash(e.Value())
+	id := e.ID()
+	key := p.hash(id)
 	if indexes, ok := p.table[key]; !ok {
 		p.table[key] = append(p.table[key], len(p.array))
 	} else if !slices.ContainsFunc(indexes, func(i int) bool {
-		return p.array[i].Value() == e.Value()
+		return p.array[i].ID() == id
 	}) {
 		p.table[key] = append(p.table[key], len(p.array))
 	} else {
 		return
 	}
 	p.array = append(p.array, e)
+	p.items = append(p.items, newEntryItem(e))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) grow(n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.array = slices.Grow(p.array, n)
+	p.items = slices.Grow(p.items, n)
+	if len(p.table) == 0 {
+		p.table = make(map[uint32][]int, n)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) removeSource(source string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := 0
+	for i, item := range p.items {
+		if !item.removed.Load() && item.node.Source() == source {
+			p.drop(i)
+			count++
+		}
+	}
+	return count
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) remove(ids ...string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		for _, i := range p.table[p.hash(id)] {
+			if p.array[i].ID() == id {
+				p.drop(i)
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) drop(index int) {
+	p.items[index].removed.Store(true)
+	p.removed++
+	key := p.hash(p.array[index].ID())
+	p.table[key] = slices.DeleteFunc(p.table[key], func(i int) bool {
+		return i == index
+	})
+	if len(p.table[key]) == 0 {
+		delete(p.table, key)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) compact() bool {
+	// NOTE: Compacting moves the entries to new indexes, it must not run
+	// while a filter holds them.
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.removed == 0 || p.removed*2 < len(p.array) {
+		return false
+	}
+
+	size := len(p.array) - p.removed
+	array := make([]EntryNode, 0, size)
+	items := make([]*entryItem, 0, size)
+	table := make(map[uint32][]int, size)
+	for i, item := range p.items {
+		if item.removed.Load() {
+			continue
+		}
+		key := p.hash(p.array[i].ID())
+		table[key] = append(table[key], len(array))
+		array = append(array, p.array[i])
+		items = append(items, item)
+	}
+	p.array, p.items, p.table, p.removed = array, items, table, 0
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newEntryItem(node EntryNode) *entryItem {
+	name := util.ToChars([]byte(node.Value()))
+	text := name
+	if len(node.Keywords()) > 0 {
+		text = util.ToChars([]byte(searchText(node)))
+	}
+	return &entryItem{node: node, name: name, text: text}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) snapshot() []*entryItem {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.items
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryHashTable) transform(strs []string) []EntryNode {
+func (p *EntryHashTable) lookup(id string) (int, bool) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	var indexes []int
-	for _, str := range strs {
-		key := p.hash(str)
-		if i, ok := p.table[key]; ok {
-			indexes = append(indexes, i...)
-		} else {
-			debug.PrintStack()
-			log.Fatalf(`Key %d not found in storage`, key)
+	for _, i := range p.table[p.hash(id)] {
+		if p.array[i].ID() == id {
+			return i, true
 		}
 	}
+	return -1, false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) get(index int) (EntryNode, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if index < 0 || index >= len(p.array) || p.items[index].removed.Load() {
+		return nil, false
+	}
+	return p.array[index], true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryHashTable) transform(indexes []int) []EntryNode {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	indexes = slices.Clone(indexes)
 	slices.Sort(indexes)
 	var nodes []EntryNode
-	for _, idx := range indexes {
+	for _, idx := range slices.Compact(indexes) {
+		if idx < 0 || idx >= len(p.array) || p.items[idx].removed.Load() {
+			log.Printf(`Index %d not found in storage`, idx)
+			continue
+		}
 		nodes = append(nodes, p.array[idx])
 	}
 	return nodes
@@ -140,9 +415,16 @@ func (p *EntryHashTable) transform(strs []string) []EntryNode {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryHashTable) forEach(start, end int, callback func(string) bool) {
-	for i := start; i < end; i++ {
-		if !callback(p.array[i].Value()) {
+func (p *EntryHashTable) forEach(
+	start, end int,
+	callback func(int, *entryItem) bool,
+) {
+	items := p.snapshot()
+	for i := start; i < end && i < len(items); i++ {
+		if items[i].removed.Load() {
+			continue
+		}
+		if !callback(i, items[i]) {
 			break
 		}
 	}
@@ -150,12 +432,20 @@ func (p *EntryHashTable) forEach(start, end int, callback func(string) bool) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryHashTable) traverse(start int, callback func(string) bool) {
-	for i := start; i < p.len(); i++ {
-		if !callback(p.array[i].Value()) {
-			break
+func (p *EntryHashTable) traverse(
+	start int,
+	callback func(int, *entryItem) bool,
+) int {
+	items := p.snapshot()
+	for i := start; i < len(items); i++ {
+		if items[i].removed.Load() {
+			continue
+		}
+		if !callback(i, items[i]) {
+			return i + 1
 		}
 	}
+	return max(start, len(items))
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -1,8 +1,18 @@
 package dsearch
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"math"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+
+	"github.com/junegunn/fzf/src/util"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -11,19 +21,63 @@ type IEntryManager interface {
 	LoadEntries(...func(chan *Entry))
 	FilterEntry(string) []EntryNode
 	StopFilter()
+	SetMatcher(Matcher)
+	SetIndex(path string)
+	SaveIndex() error
+	ReplaceEntries(source string, entries []*Entry)
+	UpdateEntries(ctx context.Context, update EntryUpdate)
+	Actions(EntryNode) []Action
+	Execute(EntryNode)
 }
 
 type EntryManager struct {
+	cfg         FzfConfig
+	history     IHistory
 	storage     IEntryHashTable
-	fzfDelegate IFzfDelegate
+	matcher     Matcher
+	providers   []Provider
+	index       string
+	lastQuery   string
+	cancel      context.CancelFunc
 	mutex       sync.Mutex
 	cond        sync.Cond
 	dataReady   bool
 	dataPending bool
 	state       FilterState
+	refreshing  bool
+	refreshLate bool
 	sigRefresh  SigRefresh
 }
 
+type scoredNode struct {
+	node  EntryNode
+	index int
+	score int
+}
+
+type visitor func(int, *entryItem)
+
+type collector struct {
+	mutex   sync.Mutex
+	compare func(a, b scoredNode) int
+	results []scoredNode
+	top     []scoredNode
+	signal  SigRefresh
+}
+
+const (
+	frecencyWeight = 20
+	affinityWeight = 60
+	recentLimit    = 50
+	partialLimit   = 256
+)
+
+type pendingQuery struct {
+	provider QueryProvider
+	query    Query
+	pinned   bool
+}
+
 type FilterState int32
 
 const (
@@ -34,12 +88,20 @@ const (
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func NewEntryManager(signal SigRefresh, cfg FzfConfig) IEntryManager {
+func NewEntryManager(
+	signal SigRefresh,
+	cfg FzfConfig,
+	history IHistory,
+	providers ...Provider,
+) IEntryManager {
 	p := &EntryManager{
-		storage:     NewEntryHashTable(),
-		fzfDelegate: NewFzfDelegate(cfg),
-		sigRefresh:  signal,
-		state:       Stopped,
+		cfg:        cfg,
+		history:    history,
+		providers:  providers,
+		storage:    NewEntryHashTable(),
+		matcher:    NewMatcher(cfg),
+		sigRefresh: signal,
+		state:      Stopped,
 	}
 	p.cond = *sync.NewCond(&p.mutex)
 	return p
@@ -48,13 +110,111 @@ func NewEntryManager(signal SigRefresh, cfg FzfConfig) IEntryManager {
 ///////////////////////////////////////////////////////////////////////////////
 
 func (p *EntryManager) LoadEntries(loaders ...func(chan *Entry)) {
+	restored := p.restoreIndex()
+
 	entryChan := make(chan *Entry)
-	defer close(entryChan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.appendEntry(entryChan, restored)
+	}()
 
-	go p.appendEntry(entryChan)
 	for _, loader := range loaders {
 		loader(entryChan)
 	}
+	close(entryChan)
+	<-done
+
+	var vanished []string
+	for id, seen := range restored {
+		if !seen {
+			vanished = append(vanished, id)
+		}
+	}
+	if len(vanished) > 0 {
+		log.Printf(`Remove %d vanished entries`, p.storage.remove(vanished...))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) indexedProviders() map[string]IndexedProvider {
+	providers := make(map[string]IndexedProvider)
+	for _, provider := range p.providers {
+		if provider, ok := provider.(IndexedProvider); ok {
+			providers[provider.Name()] = provider
+		}
+	}
+	return providers
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) SetIndex(path string) {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	p.index = path
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) restoreIndex() map[string]bool {
+	p.cond.L.Lock()
+	path := p.index
+	p.cond.L.Unlock()
+
+	providers := p.indexedProviders()
+	if len(path) == 0 || len(providers) == 0 {
+		return nil
+	}
+	records, err := readIndex(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf(`Failed to read index %s, err: %v`, path, err)
+		}
+		return nil
+	}
+
+	restored := make(map[string]bool, len(records))
+	p.storage.grow(len(records))
+	for _, record := range records {
+		provider, ok := providers[record.Source]
+		if !ok {
+			continue
+		}
+		if entry := provider.Restore(record); entry != nil {
+			p.storage.emplace(entry)
+			restored[entry.ID()] = false
+		}
+	}
+	log.Printf(`Restored %d entries from %s`, len(restored), path)
+
+	p.cond.L.Lock()
+	p.dataPending = true
+	p.cond.Broadcast()
+	p.cond.L.Unlock()
+	emit(p.sigRefresh, p.storage.getRawData())
+	return restored
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) SaveIndex() error {
+	p.cond.L.Lock()
+	path := p.index
+	p.cond.L.Unlock()
+
+	providers := p.indexedProviders()
+	if len(path) == 0 || len(providers) == 0 {
+		return nil
+	}
+	var records []IndexRecord
+	for _, node := range p.storage.getRawData() {
+		if _, ok := providers[node.Source()]; ok {
+			records = append(records, newIndexRecord(node))
+		}
+	}
+	return writeIndex(path, records)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -68,7 +228,22 @@ func emit(signal SigRefresh, nodes []EntryNode) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryManager) appendEntry(entryChan chan *Entry) {
+func (p *EntryManager) reconcile(entry *Entry, restored map[string]bool) {
+	id := entry.ID()
+	if seen, ok := restored[id]; ok && !seen {
+		restored[id] = true
+		p.storage.replace(entry)
+		return
+	}
+	p.storage.emplace(entry)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) appendEntry(
+	entryChan chan *Entry,
+	restored map[string]bool,
+) {
 	shouldEmit := func() bool {
 		p.cond.L.Lock()
 		defer p.cond.L.Unlock()
@@ -79,7 +254,7 @@ func (p *EntryManager) appendEntry(entryChan chan *Entry) {
 	}
 
 	for entry := range entryChan {
-		p.storage.emplace(entry)
+		p.reconcile(entry, restored)
 		if shouldEmit() {
 			emit(p.sigRefresh, p.storage.getRawData())
 		}
@@ -93,10 +268,107 @@ func (p *EntryManager) appendEntry(entryChan chan *Entry) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func (p *EntryManager) ReplaceEntries(source string, entries []*Entry) {
+	p.storage.removeSource(source)
+	for _, entry := range entries {
+		p.storage.emplace(entry)
+	}
+
+	p.cond.L.Lock()
+	if p.state == Stopped {
+		p.storage.compact()
+	}
+	p.dataPending = true
+	p.cond.Broadcast()
+	p.cond.L.Unlock()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) UpdateEntries(ctx context.Context, update EntryUpdate) {
+	if update.replace {
+		p.storage.removeSource(update.source)
+	}
+	if len(update.dirs) > 0 {
+		var ids []string
+		for _, node := range p.storage.getRawData() {
+			if node.Source() == update.source && inDirs(node.Path(), update.dirs) {
+				ids = append(ids, node.ID())
+			}
+		}
+		p.storage.remove(ids...)
+	}
+	p.storage.remove(update.removed...)
+	for _, entry := range update.added {
+		p.storage.emplace(entry)
+	}
+
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	if p.state == Stopped {
+		p.storage.compact()
+	}
+	p.dataPending = true
+	p.cond.Broadcast()
+
+	// NOTE: A single routine filters the updated entries again, the updates
+	// coming in meanwhile are folded into its next round.
+	if p.refreshing {
+		p.refreshLate = true
+		return
+	}
+	p.refreshing = true
+	go p.refresh(ctx)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) refresh(ctx context.Context) {
+	for {
+		p.cond.L.Lock()
+		query := p.lastQuery
+		p.refreshLate = false
+		p.cond.L.Unlock()
+
+		nodes := p.FilterEntry(query)
+		p.cond.L.Lock()
+		current := p.lastQuery == query
+		p.cond.L.Unlock()
+		if current && p.sigRefresh != nil {
+			select {
+			case p.sigRefresh <- RefreshedMsg{nodes}:
+			case <-ctx.Done():
+			}
+		}
+
+		p.cond.L.Lock()
+		if !p.refreshLate || ctx.Err() != nil {
+			p.refreshing = false
+			p.cond.L.Unlock()
+			return
+		}
+		p.cond.L.Unlock()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func inDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func (p *EntryManager) StopFilter() {
 	p.cond.L.Lock()
 	if p.state == Filtering {
 		p.state = Stopping
+		p.cancel()
 		p.cond.Broadcast()
 	}
 	p.cond.L.Unlock()
@@ -104,10 +376,21 @@ func (p *EntryManager) StopFilter() {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func (p *EntryManager) SetMatcher(matcher Matcher) {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	p.matcher = matcher
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func (p *EntryManager) FilterEntry(query string) []EntryNode {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	defer func() {
 		p.cond.L.Lock()
 		p.state = Stopped
+		p.cancel = nil
 		p.cond.Signal()
 		p.cond.L.Unlock()
 	}()
@@ -117,103 +400,360 @@ func (p *EntryManager) FilterEntry(query string) []EntryNode {
 		p.cond.Wait()
 	}
 	p.state = Filtering
+	p.cancel = cancel
+	p.lastQuery = query
+	matcher := p.matcher
 	p.cond.L.Unlock()
 
-	if entry := loadCalculator(query); entry != nil {
-		p.storage.emplace(entry)
-		query = entry.name
+	scope, query := p.cfg.scope(query)
+	if len(strings.TrimSpace(query)) == 0 {
+		return p.recent(scope)
 	}
 
-	return p.storage.transform(*p.filterAsync(query))
+	pattern, operators := p.compile(matcher, query)
+	queries := p.query(scope, strings.TrimSpace(query), operators)
+	return p.rank(*p.filterAsync(
+		ctx, pattern, scope, p.affinities(query), queries))
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryManager) filterSync(query string) *[]string {
-	var strs []string
-	foundFn := func(str string) {
-		strs = append(strs, str)
-		emit(p.sigRefresh, p.storage.transform(strs))
+func (p *EntryManager) query(
+	scope, text string,
+	operators bool,
+) []pendingQuery {
+	if len(scope) == 0 && operators {
+		return nil
+	}
+
+	var queries []pendingQuery
+	for _, provider := range p.providers {
+		queryProvider, ok := provider.(QueryProvider)
+		if !ok || len(scope) > 0 && provider.Name() != scope {
+			continue
+		}
+		_, ranked := provider.(RankedQueryProvider)
+		queries = append(queries, pendingQuery{
+			provider: queryProvider,
+			query:    Query{text: text, scoped: len(scope) > 0},
+			pinned:   len(scope) > 0 || !ranked,
+		})
 	}
-	p.fzfDelegate.ExecuteSync(query, foundFn, p.readSync(0))
-	return &strs
+	return queries
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryManager) filterAsync(query string) *[]string {
-	var workers []*sync.WaitGroup
-	var strs []string
-	var mutex sync.Mutex
+func (p *EntryManager) Actions(node EntryNode) []Action {
+	actions := defaultActions(node, "Open")
+	if provider := findProvider(p.providers, node.Source()); provider != nil {
+		actions = provider.Actions(node)
+	}
+	if node.Source() != SourceFiles || isDirEntry(node) {
+		return actions
+	}
 
-	defer func() {
-		for _, worker := range workers {
-			worker.Wait()
+	var openWith []Action
+	for _, provider := range p.providers {
+		if provider, ok := provider.(OpenWithProvider); ok {
+			openWith = append(openWith, provider.OpenWith(node.Path())...)
 		}
-	}()
+	}
+	return slices.Insert(actions, min(1, len(actions)), openWith...)
+}
 
-	foundFn := func(str string) {
-		mutex.Lock()
-		defer mutex.Unlock()
-		strs = append(strs, str)
-		emit(p.sigRefresh, p.storage.transform(strs))
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) Execute(node EntryNode) {
+	if actions := p.Actions(node); len(actions) > 0 {
+		actions[0].Execute()
+		return
 	}
+	node.Execute()
+}
+
+///////////////////////////////////////////////////////////////////////////////
 
-	routines := runtime.NumCPU() - 1
+func (p *EntryManager) compile(matcher Matcher, query string) (Pattern, bool) {
+	if !p.cfg.extended || matcher.Name() == MatcherRegexp {
+		return matcher.Compile(query), false
+	}
+	terms := parseExtended(query)
+	return compileExtended(terms, matcher, p.cfg), terms.hasOperators()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) affinities(query string) map[string]float64 {
+	if p.history == nil {
+		return nil
+	}
+	return p.history.Affinities(query)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) boost(id string, affinities map[string]float64) int {
+	if p.history == nil {
+		return 0
+	}
+	frecency := frecencyWeight * math.Log2(1+p.history.Frecency(id))
+	affinity := affinityWeight * math.Log2(1+affinities[id])
+	return int(frecency + affinity)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func inScope(scope string, node EntryNode) bool {
+	return len(scope) == 0 || node.Source() == scope
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) recent(scope string) []EntryNode {
+	nodes := p.storage.getRawData()
+	result := make([]EntryNode, 0, len(nodes))
+	seen := make(map[string]struct{})
+	if p.history != nil && p.cfg.sort != SortIndex {
+		for _, id := range p.history.Top(recentLimit) {
+			i, ok := p.storage.lookup(id)
+			if !ok {
+				continue
+			}
+			if node, ok := p.storage.get(i); ok && inScope(scope, node) {
+				result = append(result, node)
+				seen[node.ID()] = struct{}{}
+			}
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := seen[node.ID()]; !ok && inScope(scope, node) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) match(
+	pattern Pattern,
+	affinities map[string]float64,
+	index int,
+	item *entryItem,
+	slab *util.Slab,
+) (scoredNode, bool) {
+	var score int
+	var positions []int
+	if len(item.node.Keywords()) == 0 {
+		s, pos, ok := pattern.Match(&item.name, true, slab)
+		if !ok {
+			return scoredNode{}, false
+		}
+		score, positions = s, pos
+	} else {
+		s, _, ok := pattern.Match(&item.text, false, slab)
+		if !ok {
+			return scoredNode{}, false
+		}
+		score = s / 2
+		if s, pos, ok := pattern.Match(&item.name, true, slab); ok {
+			score, positions = max(score, s), pos
+		}
+	}
+
+	score += p.boost(item.node.ID(), affinities)
+	return scoredNode{
+		node: &MatchedEntry{
+			EntryNode: item.node,
+			positions: positions,
+			score:     score,
+		},
+		index: index,
+		score: score,
+	}, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) priority(source string) int {
+	if i := slices.Index(p.cfg.priority, source); i >= 0 {
+		return i
+	}
+	return len(p.cfg.priority)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) compare(a, b scoredNode) int {
+	if p.cfg.sort == SortIndex {
+		return cmp.Compare(a.index, b.index)
+	}
+	return cmp.Or(
+		cmp.Compare(b.score, a.score),
+		cmp.Compare(len(a.node.Value()), len(b.node.Value())),
+		cmp.Compare(
+			p.priority(a.node.Source()),
+			p.priority(b.node.Source())),
+		cmp.Compare(a.index, b.index))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) rank(results []scoredNode) []EntryNode {
+	results = slices.Clone(results)
+	slices.SortFunc(results, p.compare)
+
+	var nodes []EntryNode
+	for _, result := range results {
+		nodes = append(nodes, result.node)
+	}
+	return nodes
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *collector) add(result scoredNode) {
+	if nodes := p.insert(result); p.signal != nil {
+		emit(p.signal, nodes)
+		// NOTE: Matching never blocks, yield so the partial results can be
+		// picked up while filtering.
+		runtime.Gosched()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *collector) insert(result scoredNode) []EntryNode {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.results = append(p.results, result)
+	if i, _ := slices.BinarySearchFunc(
+		p.top, result, p.compare,
+	); i < partialLimit {
+		p.top = slices.Insert(p.top, i, result)
+		p.top = p.top[:min(len(p.top), partialLimit)]
+	}
+	if p.signal == nil {
+		return nil
+	}
+
+	nodes := make([]EntryNode, 0, len(p.top))
+	for _, result := range p.top {
+		nodes = append(nodes, result.node)
+	}
+	return nodes
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) filterAsync(
+	ctx context.Context,
+	pattern Pattern,
+	scope string,
+	affinities map[string]float64,
+	queries []pendingQuery,
+) *[]scoredNode {
+	results := collector{compare: p.compare, signal: p.sigRefresh}
+	var workers sync.WaitGroup
+	for _, query := range queries {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			slab := newSlab()
+			for _, entry := range query.provider.Query(ctx, query.query) {
+				if query.pinned {
+					results.add(scoredNode{
+						node: &MatchedEntry{
+							EntryNode: entry,
+							score:     math.MaxInt32,
+						},
+						index: -1,
+						score: math.MaxInt32,
+					})
+				} else if result, ok := p.match(
+					pattern, affinities, -1, newEntryItem(entry), slab,
+				); ok {
+					results.add(result)
+				}
+			}
+		}()
+	}
+
+	routines := max(runtime.NumCPU()-1, 1)
 	chunk := p.storage.len() / routines
 	for i := range routines {
-		var readFn func(FzfStream)
+		var readFn func(visitor)
 		if i < routines-1 {
 			readFn = p.readAsync(i*chunk, i*chunk+chunk)
 		} else {
 			readFn = p.readSync(i * chunk)
 		}
-		workers = append(
-			workers,
-			p.fzfDelegate.ExecuteAsync(query, foundFn, readFn))
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			slab := newSlab()
+			readFn(func(index int, item *entryItem) {
+				if !inScope(scope, item.node) {
+					return
+				}
+				if result, ok := p.match(
+					pattern, affinities, index, item, slab,
+				); ok {
+					results.add(result)
+				}
+			})
+		}()
 	}
-	return &strs
+	workers.Wait()
+	return &results.results
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryManager) readSync(start int) func(FzfStream) {
-	persist := func() bool {
+func (p *EntryManager) readSync(start int) func(visitor) {
+	persist := func(next int) bool {
 		p.cond.L.Lock()
 		defer p.cond.L.Unlock()
 
-		filtering := p.state == Filtering
-		hasData := p.dataPending || p.dataReady
-		for filtering && !hasData {
+		for p.state == Filtering && !p.dataPending && !p.dataReady {
 			p.cond.Wait()
-			filtering = p.state == Filtering
-			hasData = p.dataPending || p.dataReady
 		}
-		if p.dataPending {
-			p.dataPending = false
-		}
-		return filtering
+		p.dataPending = false
+		return p.state == Filtering &&
+			(!p.dataReady || next < p.storage.len())
 	}
-	return func(stream FzfStream) {
-		p.storage.traverse(start, func(val string) bool {
-			stream <- val
-			return persist()
-		})
+	return func(visit visitor) {
+		next := start
+		for {
+			next = p.storage.traverse(next, func(i int, item *entryItem) bool {
+				visit(i, item)
+				return p.filtering()
+			})
+			if !persist(next) {
+				return
+			}
+		}
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *EntryManager) readAsync(start, end int) func(FzfStream) {
-	persist := func() bool {
-		p.cond.L.Lock()
-		defer p.cond.L.Unlock()
-		return p.state == Filtering
-	}
-	return func(stream FzfStream) {
-		p.storage.forEach(start, end, func(s string) bool {
-			stream <- s
-			return persist()
+func (p *EntryManager) filtering() bool {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	return p.state == Filtering
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *EntryManager) readAsync(start, end int) func(visitor) {
+	return func(visit visitor) {
+		p.storage.forEach(start, end, func(i int, item *entryItem) bool {
+			visit(i, item)
+			return p.filtering()
 		})
 	}
 }
@@ -1,14 +1,25 @@
 package dsearch
 
 import (
+	"context"
 	"math/rand"
+	"path/filepath"
+	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
+var dummyCfg = FzfConfig{matcher: MatcherExact, ignoreCase: true}
+
+var allFiles = []*fileRoot{
+	newFileRoot(RootConfig{Path: "~", Hidden: true, NoIgnore: true}),
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkLoadEntries(b *testing.B) {
@@ -22,16 +33,39 @@ func BenchmarkLoadEntries(b *testing.B) {
 	// commit 115624de830ea73ed931c8ae3bbf5ab4964ac85a
 	// BenchmarkLoadEntries-16              100         463195134 ns/op
 	for i := 0; i < b.N; i++ {
-		m := NewEntryManager(nil, FzfConfig{true, true, 0})
+		m := NewEntryManager(nil, dummyCfg, nil)
 		m.LoadEntries(
-			func(c chan *Entry) { loadApplications(c) },
-			func(c chan *Entry) { loadFiles(c, true) },
+			func(c chan *Entry) {
+				loadApplications(
+					context.Background(), c, DefaultConfig().Launcher)
+			},
+			func(c chan *Entry) { loadFiles(context.Background(), c, allFiles, nil) },
 		)
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func BenchmarkRestoreIndex(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "index")
+	providers := []Provider{
+		&appProvider{cfg: DefaultConfig().Launcher},
+		&fileProvider{roots: allFiles},
+	}
+	m := NewEntryManager(nil, dummyCfg, nil, providers...)
+	m.SetIndex(path)
+	loadProviders(context.Background(), m, providers)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m := NewEntryManager(nil, dummyCfg, nil, providers...)
+		m.SetIndex(path)
+		m.(*EntryManager).restoreIndex()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func BenchmarkFilterEntry(b *testing.B) {
 	// NOTE: Records
 	// commit 6e1973f00fccdfc2da12ab91a0320d32d04f3657
@@ -46,8 +80,12 @@ func BenchmarkFilterEntry(b *testing.B) {
 	// BenchmarkFilterEntry-16              100         256185131 ns/op
 	// commit 115624de830ea73ed931c8ae3bbf5ab4964ac85a
 	// BenchmarkFilterEntry-16              100         221225735 ns/op
+	// commit 5cf3584f9d45bae548078468682c681eb30f4a83
+	// BenchmarkFilterEntry                  20         1696838771 ns/op
+	// commit 72474fd4e1bfe24b5deb5bcfe5a15eeba30222b1
+	// BenchmarkFilterEntry                   5           77983656 ns/op
 
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	m.LoadEntries(func(entryChan chan *Entry) {
 		for i := uint64(0); i < 1000000; i++ {
 			entryChan <- &Entry{
@@ -62,6 +100,28 @@ func BenchmarkFilterEntry(b *testing.B) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func BenchmarkFilterEntryFuzzy(b *testing.B) {
+	// NOTE: Records
+	// commit 5cf3584f9d45bae548078468682c681eb30f4a83
+	// BenchmarkFilterEntryFuzzy              5         2194072794 ns/op
+	// commit 72474fd4e1bfe24b5deb5bcfe5a15eeba30222b1
+	// BenchmarkFilterEntryFuzzy              5           81711694 ns/op
+
+	m := NewEntryManager(nil, FzfConfig{matcher: MatcherFuzzy, ignoreCase: true}, nil)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		for i := uint64(0); i < 1000000; i++ {
+			entryChan <- &Entry{
+				name: strconv.FormatUint(i, 10) + "_"}
+		}
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.FilterEntry("4269_")
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func extract(nodes []EntryNode) []string {
 	var result []string
 	for _, node := range nodes {
@@ -74,7 +134,7 @@ func extract(nodes []EntryNode) []string {
 
 func TestFilterEntry(t *testing.T) {
 	var expected, result []string
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 1000000; i++ {
 			entryChan <- &Entry{
@@ -114,13 +174,147 @@ func TestFilterEntry(t *testing.T) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func TestFilterEntryKeywords(t *testing.T) {
+	var expected, result []string
+	m := NewEntryManager(nil, dummyCfg, nil)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "Firefox", keywords: []string{"Web Browser"}}
+		entryChan <- &Entry{name: "Browser Tabs"}
+		entryChan <- &Entry{name: "Chromium", keywords: []string{"Web Browser"}}
+		entryChan <- &Entry{name: "Files", keywords: []string{"nautilus"}}
+	})
+
+	result = extract(m.FilterEntry("browser"))
+	expected = []string{"Browser Tabs", "Firefox", "Chromium"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	result = extract(m.FilterEntry("nautilus"))
+	expected = []string{"Files"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryRanking(t *testing.T) {
+	var expected, result []string
+	loadDummies := func(entryChan chan *Entry) {
+		entryChan <- &Entry{id: "1", name: "axxxxxxbxxxxxxc", source: SourceFiles}
+		entryChan <- &Entry{id: "2", name: "abc.txt", source: SourceFiles}
+		entryChan <- &Entry{id: "3", name: "xabcx", source: SourceFiles}
+		entryChan <- &Entry{id: "4", name: "abc", source: SourceFiles}
+		entryChan <- &Entry{id: "5", name: "abc", source: SourceApplications}
+	}
+
+	m := NewEntryManager(nil, FzfConfig{
+		ignoreCase: true,
+		priority:   []string{SourceApplications, SourceFiles},
+	}, nil)
+	m.LoadEntries(loadDummies)
+	nodes := m.FilterEntry("abc")
+	result = extract(nodes)
+	expected = []string{"abc", "abc", "abc.txt", "xabcx", "axxxxxxbxxxxxxc"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+	if nodes[0].Source() != SourceApplications {
+		t.Errorf(`Expected source %s got %s`, SourceApplications, nodes[0].Source())
+	}
+	positions := nodes[3].(*MatchedEntry).Positions()
+	if !slices.Equal([]int{1, 2, 3}, positions) {
+		t.Errorf(`Expected positions %v got %v`, []int{1, 2, 3}, positions)
+	}
+
+	m = NewEntryManager(nil, FzfConfig{ignoreCase: true, sort: SortIndex}, nil)
+	m.LoadEntries(loadDummies)
+	result = extract(m.FilterEntry("abc"))
+	expected = []string{"axxxxxxbxxxxxxc", "abc.txt", "xabcx", "abc", "abc"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryFrecency(t *testing.T) {
+	var expected, result []string
+	history := NewHistory("")
+	for range 10 {
+		history.Record("firefox", "")
+	}
+	history.Record("files", "")
+
+	m := NewEntryManager(nil, dummyCfg, history)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{id: "fire", name: "fire"}
+		entryChan <- &Entry{id: "firefox", name: "firefox"}
+		entryChan <- &Entry{id: "files", name: "files"}
+		entryChan <- &Entry{id: "firewall", name: "firewall"}
+	})
+
+	result = extract(m.FilterEntry("fire"))
+	expected = []string{"firefox", "fire", "firewall"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	result = extract(m.FilterEntry(""))
+	expected = []string{"firefox", "files", "fire", "firewall"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryAffinity(t *testing.T) {
+	var expected, result []string
+	history := NewHistory("")
+	m := NewEntryManager(nil, FzfConfig{matcher: MatcherFuzzy, ignoreCase: true}, history)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{id: "ffmpeg", name: "ffmpeg"}
+		entryChan <- &Entry{id: "firefox", name: "Firefox"}
+	})
+
+	result = extract(m.FilterEntry("ff"))
+	expected = []string{"ffmpeg", "Firefox"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	history.Record("firefox", "ff")
+	history.Record("ffmpeg", "")
+	for _, query := range []string{"ff", "ffo"} {
+		result = extract(m.FilterEntry(query))
+		expected = []string{"Firefox", "ffmpeg"}
+		if query == "ffo" {
+			expected = []string{"Firefox"}
+		}
+		if !slices.Equal(expected, result) {
+			t.Errorf(`%s: Expected %v got %v`, query, expected, result)
+		}
+	}
+
+	history.Forget("firefox")
+	result = extract(m.FilterEntry("ff"))
+	expected = []string{"ffmpeg", "Firefox"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func TestFilterEntryBeforeDataReadyCase1(t *testing.T) {
 	// NOTE:
 	// Begin to filter while loading all entries.
 	// The result contains only loaded entries after starting point.
 	var expected, result []string
 	var wg, fin sync.WaitGroup
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 100000; i++ {
 			entryChan <- &Entry{
@@ -152,7 +346,7 @@ func TestFilterEntryBeforeDataReadyCase2(t *testing.T) {
 	// The result contains only loaded entries before starting point.
 	var expected, result []string
 	var wg, fin sync.WaitGroup
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 100000; i++ {
 			entryChan <- &Entry{
@@ -184,7 +378,7 @@ func TestFilterEntryBeforeDataReadyCase3(t *testing.T) {
 	// The result contains loaded entries before and after starting point.
 	var expected, result []string
 	var wg, fin sync.WaitGroup
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 100000; i++ {
 			entryChan <- &Entry{
@@ -221,10 +415,35 @@ func TestFilterEntryBeforeDataReadyCase3(t *testing.T) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+func TestFilterEntryRemoved(t *testing.T) {
+	m := NewEntryManager(nil, dummyCfg, nil)
+	m.LoadEntries(func(c chan *Entry) {
+		c <- &Entry{id: "a", name: "alpha"}
+		c <- &Entry{id: "b", name: "beta"}
+	})
+	m.(*EntryManager).storage.remove("b")
+
+	// NOTE: The last chunk must not wait for the removed entries at the end
+	// of the storage.
+	done := make(chan []string)
+	go func() { done <- extract(m.FilterEntry("a")) }()
+	select {
+	case result := <-done:
+		expected := []string{"alpha"}
+		if !slices.Equal(expected, result) {
+			t.Errorf(`Expected %v got %v`, expected, result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf(`FilterEntry did not return`)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
 func TestStopFilter(t *testing.T) {
 	var fin sync.WaitGroup
 	refreshCon := make(SigRefresh)
-	m := NewEntryManager(refreshCon, FzfConfig{true, true, 0})
+	m := NewEntryManager(refreshCon, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 100000; i++ {
 			entryChan <- &Entry{
@@ -271,7 +490,7 @@ func TestStopFilter(t *testing.T) {
 
 func TestSynchronizeFilterEntry(t *testing.T) {
 	var fin sync.WaitGroup
-	m := NewEntryManager(nil, FzfConfig{true, true, 0})
+	m := NewEntryManager(nil, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 100000; i++ {
 			entryChan <- &Entry{
@@ -306,7 +525,7 @@ func TestSynchronizeFilterEntry(t *testing.T) {
 func TestSynchronizeStopThenFilter(t *testing.T) {
 	var fin sync.WaitGroup
 	refreshCon := make(SigRefresh)
-	m := NewEntryManager(refreshCon, FzfConfig{true, true, 0})
+	m := NewEntryManager(refreshCon, dummyCfg, nil)
 	loadDummies := func(entryChan chan *Entry) {
 		for i := uint64(0); i < 1000000; i++ {
 			entryChan <- &Entry{
@@ -320,17 +539,28 @@ func TestSynchronizeStopThenFilter(t *testing.T) {
 	expected := len(m.FilterEntry(query))
 	half := expected / 2
 	nums.Store(10)
+	// NOTE: Filters emit on refreshCon, it is closed once none is running.
+	var mutex sync.Mutex
+	running, closed := 0, false
+	idle := sync.NewCond(&mutex)
 	stopThenFilter := func() {
-		nums.Add(-1)
-		if nums.Load() == 1 && result != expected {
-			t.Errorf(`Expected %d got %d`, expected, result)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if closed {
 			return
 		}
+		nums.Add(-1)
+		running += 1
 		m.StopFilter()
-		result = len(m.FilterEntry(query))
-		if result < half {
-			t.Errorf(`Expected >=%d got %d`, half, result)
-		}
+		go func() {
+			if filtered := len(m.FilterEntry(query)); filtered < half {
+				t.Errorf(`Expected >=%d got %d`, half, filtered)
+			}
+			mutex.Lock()
+			running -= 1
+			idle.Signal()
+			mutex.Unlock()
+		}()
 	}
 	go func() {
 		defer fin.Done()
@@ -338,16 +568,263 @@ func TestSynchronizeStopThenFilter(t *testing.T) {
 		count := 0
 		for range refreshCon {
 			count += 1
-			if nums.Load() > 1 && count >= rand.Intn(half) {
+			// NOTE: A filter emits once per result, it is stopped after half
+			// of them. The stopped one may still emit once per routine.
+			if nums.Load() > 1 &&
+				count >= half+runtime.NumCPU()+rand.Intn(half) {
 				count = 0
-				go stopThenFilter()
+				stopThenFilter()
 			}
 		}
 	}()
-	go stopThenFilter()
+	stopThenFilter()
 
+	mutex.Lock()
+	for running > 0 {
+		idle.Wait()
+	}
+	closed = true
+	mutex.Unlock()
+	if result = len(m.FilterEntry(query)); result != expected {
+		t.Errorf(`Expected %d got %d`, expected, result)
+	}
 	close(refreshCon)
 	fin.Wait()
 }
 
 ///////////////////////////////////////////////////////////////////////////////
+
+func TestSetMatcher(t *testing.T) {
+	var expected, result []string
+	m := NewEntryManager(nil, dummyCfg, nil)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "Visual Studio Code"}
+		entryChan <- &Entry{name: "vscode.txt"}
+	})
+
+	result = extract(m.FilterEntry("vsc"))
+	expected = []string{"vscode.txt"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	m.SetMatcher(NewMatcher(FzfConfig{matcher: MatcherPrefix, ignoreCase: true}))
+	result = extract(m.FilterEntry("vsc"))
+	expected = []string{"Visual Studio Code", "vscode.txt"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryExtended(t *testing.T) {
+	m := NewEntryManager(nil, FzfConfig{
+		matcher:    MatcherFuzzy,
+		extended:   true,
+		ignoreCase: true,
+		sort:       SortIndex,
+	}, nil)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "firefox"}
+		entryChan <- &Entry{name: "fire.txt"}
+		entryChan <- &Entry{name: "libreoffice"}
+		entryChan <- &Entry{name: "vim"}
+		entryChan <- &Entry{name: "gvim"}
+		entryChan <- &Entry{name: "f-i-r-e"}
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"fire", []string{"firefox", "fire.txt", "f-i-r-e"}},
+		{"'fire", []string{"firefox", "fire.txt"}},
+		{"^lib", []string{"libreoffice"}},
+		{"vim$", []string{"vim", "gvim"}},
+		{"^vim$", []string{"vim"}},
+		{"fire !txt", []string{"firefox", "f-i-r-e"}},
+		{"!^f", []string{"libreoffice", "vim", "gvim"}},
+		{"!vim$ !^f", []string{"libreoffice"}},
+		{"^lib | ^vim", []string{"libreoffice", "vim"}},
+		{"'fire | vim$ !txt", []string{"firefox", "vim", "gvim"}},
+	}
+	for _, test := range tests {
+		result := extract(m.FilterEntry(test.query))
+		if !slices.Equal(test.expected, result) {
+			t.Errorf(`%q: Expected %v got %v`, test.query, test.expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryCalculator(t *testing.T) {
+	m := NewEntryManager(nil, FzfConfig{
+		matcher:    MatcherFuzzy,
+		extended:   true,
+		ignoreCase: true,
+	}, nil, &calculatorProvider{})
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "2048"}
+		entryChan <- &Entry{name: "12"}
+	})
+
+	result := extract(m.FilterEntry("1+1"))
+	if len(result) == 0 || result[0] != "1+1 = 2" {
+		t.Errorf(`Expected the calculator first got %v`, result)
+	}
+
+	for _, query := range []string{"^2", "!2", "2$", "12 | 2"} {
+		result := extract(m.FilterEntry(query))
+		if slices.ContainsFunc(result, func(s string) bool {
+			return strings.Contains(s, "=")
+		}) {
+			t.Errorf(`%q: Expected no calculator got %v`, query, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilterEntryScope(t *testing.T) {
+	cfg := DefaultConfig()
+	fzfCfg := cfg.Search.fzfConfig()
+	fzfCfg.sort = SortIndex
+	m := NewEntryManager(nil, fzfCfg, nil, NewProviders(cfg)...)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "firefox", source: SourceApplications}
+		entryChan <- &Entry{name: "firefox.desktop", source: SourceFiles}
+		entryChan <- &Entry{name: "files", source: SourceApplications}
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"fire", []string{"firefox", "firefox.desktop"}},
+		{"a fire", []string{"firefox"}},
+		{"f fire", []string{"firefox.desktop"}},
+		{"a ", []string{"firefox", "files"}},
+		{"=2*8", []string{"2*8 = 16"}},
+		{"= ", nil},
+		{">ls -la", []string{"ls -la"}},
+		{"?fire fox", []string{"fire fox"}},
+	}
+	for _, test := range tests {
+		result := extract(m.FilterEntry(test.query))
+		if !slices.Equal(test.expected, result) {
+			t.Errorf(`%q: Expected %v got %v`, test.query, test.expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestUpdateEntries(t *testing.T) {
+	signal := make(SigRefresh)
+	m := NewEntryManager(signal, dummyCfg, nil)
+	m.LoadEntries(func(c chan *Entry) {
+		for _, path := range []string{"/a/x", "/a/y", "/b/z"} {
+			c <- &Entry{source: SourceFiles, name: path, path: path}
+		}
+		c <- &Entry{source: SourceApplications, name: "/a/app", path: "/a/app"}
+	})
+	m.FilterEntry("/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan []string, 1024)
+	go func() {
+		for {
+			select {
+			case msg := <-signal:
+				result := extract(msg.nodes)
+				slices.Sort(result)
+				results <- result
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	m.UpdateEntries(ctx, EntryUpdate{
+		source:  SourceFiles,
+		dirs:    []string{"/a"},
+		removed: []string{"/b/z"},
+		added:   []*Entry{{source: SourceFiles, name: "/c/w", path: "/c/w"}},
+	})
+
+	expected := []string{"/a/app", "/c/w"}
+	var result []string
+	for !slices.Equal(expected, result) {
+		select {
+		case result = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatalf(`Expected %v got %v`, expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestReplaceEntries(t *testing.T) {
+	m := NewEntryManager(nil, dummyCfg, nil)
+	rows := func() []*Entry {
+		return []*Entry{
+			{source: SourceDmenu, id: "a", name: "alpha"},
+			{source: SourceDmenu, id: "b", name: "beta"},
+		}
+	}
+	m.LoadEntries(func(c chan *Entry) {
+		for _, entry := range rows() {
+			c <- entry
+		}
+	})
+	for range 10 {
+		m.ReplaceEntries(SourceDmenu, rows())
+	}
+
+	expected := []string{"alpha"}
+	if result := extract(m.FilterEntry("al")); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+	if n := m.(*EntryManager).storage.len(); n > 4 {
+		t.Errorf(`Expected at most 4 stored entries got %d`, n)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestUpdateEntriesUnread(t *testing.T) {
+	m := NewEntryManager(make(SigRefresh), dummyCfg, nil)
+	m.LoadEntries(func(c chan *Entry) {
+		c <- &Entry{source: SourceFiles, name: "/a/x", path: "/a/x"}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan bool)
+	go func() {
+		for _, path := range []string{"/a/y", "/a/z", "/a/w"} {
+			m.UpdateEntries(ctx, EntryUpdate{
+				source: SourceFiles,
+				added:  []*Entry{{source: SourceFiles, name: path, path: path}},
+			})
+		}
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf(`UpdateEntries did not return`)
+	}
+
+	expected := []string{"/a/w", "/a/x", "/a/y", "/a/z"}
+	result := extract(m.FilterEntry("/a"))
+	slices.Sort(result)
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
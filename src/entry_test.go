@@ -36,25 +36,68 @@ func TestEntryHashTable(t *testing.T) {
 	}
 
 	expected = []string{"3"}
-	result = extract(p.transform(expected))
+	result = extract(p.transform(lookupAll(p, expected)))
 	slices.Sort(expected)
 	if slices.Compare(expected, result) != 0 {
 		t.Errorf(`Expected %v got %v`, expected, result)
 	}
 
 	expected = []string{"5"}
-	result = extract(p.transform(expected))
+	result = extract(p.transform(lookupAll(p, expected)))
 	slices.Sort(expected)
 	if slices.Compare(expected, result) != 0 {
 		t.Errorf(`Expected %v got %v`, expected, result)
 	}
 
 	expected = []string{"1", "6", "9"}
-	result = extract(p.transform(expected))
+	result = extract(p.transform(lookupAll(p, expected)))
 	slices.Sort(expected)
+	slices.Sort(result)
+	if slices.Compare(expected, result) != 0 {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	if _, ok := p.lookup("10"); ok {
+		t.Errorf(`Expected key %s to be missing`, "10")
+	}
+	if nodes := p.transform([]int{-1, 10, 42}); len(nodes) != 0 {
+		t.Errorf(`Expected no nodes got %v`, extract(nodes))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func lookupAll(p IEntryHashTable, ids []string) []int {
+	var indexes []int
+	for _, id := range ids {
+		if i, ok := p.lookup(id); ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestEntryHashTableIdentity(t *testing.T) {
+	var p IEntryHashTable = NewEntryHashTable()
+	p.emplace(&Entry{id: "foot.desktop", name: "Terminal"})
+	p.emplace(&Entry{id: "xterm.desktop", name: "Terminal"})
+	p.emplace(&Entry{id: "xterm.desktop", name: "XTerm"})
+
+	if p.len() != 2 {
+		t.Errorf(`Expected len %d got %d`, 2, p.len())
+	}
+
+	expected := []string{"Terminal", "Terminal"}
+	result := extract(p.transform([]int{1, 0, 1}))
 	if slices.Compare(expected, result) != 0 {
 		t.Errorf(`Expected %v got %v`, expected, result)
 	}
+
+	if i, ok := p.lookup("xterm.desktop"); !ok || i != 1 {
+		t.Errorf(`Expected index %d got %d`, 1, i)
+	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -75,8 +118,8 @@ func TestEntryForEachOrder(t *testing.T) {
 	fn(0, 3)
 	wg.Wait()
 
-	p.forEach(3, 6, func(s string) bool {
-		result = append(result, s)
+	p.forEach(3, 6, func(_ int, item *entryItem) bool {
+		result = append(result, item.node.Value())
 		return true
 	})
 
@@ -104,8 +147,8 @@ func TestEntryTraverseOrder(t *testing.T) {
 	fn(0, 3)
 	wg.Wait()
 
-	p.traverse(6, func(s string) bool {
-		result = append(result, s)
+	p.traverse(6, func(_ int, item *entryItem) bool {
+		result = append(result, item.node.Value())
 		return true
 	})
 
@@ -133,8 +176,8 @@ func TestEntryForEach(t *testing.T) {
 	go fn(3, 6)
 	wg.Wait()
 
-	p.forEach(0, 10, func(s string) bool {
-		result = append(result, s)
+	p.forEach(0, 10, func(_ int, item *entryItem) bool {
+		result = append(result, item.node.Value())
 		return true
 	})
 
@@ -163,8 +206,8 @@ func TestEntryTraverse(t *testing.T) {
 	go fn(3, 6)
 	wg.Wait()
 
-	p.traverse(0, func(s string) bool {
-		result = append(result, s)
+	p.traverse(0, func(_ int, item *entryItem) bool {
+		result = append(result, item.node.Value())
 		return true
 	})
 
@@ -176,3 +219,85 @@ func TestEntryTraverse(t *testing.T) {
 }
 
 ///////////////////////////////////////////////////////////////////////////////
+
+func TestEntryRemoveSource(t *testing.T) {
+	var p IEntryHashTable = NewEntryHashTable()
+	p.emplace(&Entry{id: "a:1", source: "a", name: "one"})
+	p.emplace(&Entry{id: "b:2", source: "b", name: "two"})
+	p.emplace(&Entry{id: "a:3", source: "a", name: "three"})
+
+	if count := p.removeSource("a"); count != 2 {
+		t.Errorf(`Expected %d removed got %d`, 2, count)
+	}
+	if _, ok := p.lookup("a:1"); ok {
+		t.Errorf(`Expected key %s to be missing`, "a:1")
+	}
+	if _, ok := p.get(0); ok {
+		t.Errorf(`Expected index %d to be removed`, 0)
+	}
+	if result := extract(p.getRawData()); !slices.Equal([]string{"two"}, result) {
+		t.Errorf(`Expected %v got %v`, []string{"two"}, result)
+	}
+
+	p.emplace(&Entry{id: "a:1", source: "a", name: "again"})
+	var result []string
+	p.traverse(0, func(_ int, item *entryItem) bool {
+		result = append(result, item.node.Value())
+		return true
+	})
+	if expected := []string{"two", "again"}; !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestEntryCompact(t *testing.T) {
+	var p IEntryHashTable = NewEntryHashTable()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		p.emplace(&Entry{id: id, name: id})
+	}
+
+	p.remove("1")
+	if p.compact() {
+		t.Errorf(`Expected no compaction below half removed`)
+	}
+	p.remove("3")
+	if !p.compact() || p.len() != 2 {
+		t.Errorf(`Expected %d entries got %d`, 2, p.len())
+	}
+	for i, id := range []string{"2", "4"} {
+		if index, ok := p.lookup(id); !ok || index != i {
+			t.Errorf(`Expected key %s at %d got %d`, id, i, index)
+		}
+	}
+
+	p.emplace(&Entry{id: "1", name: "again"})
+	if result := extract(p.getRawData()); !slices.Equal(
+		[]string{"2", "4", "again"}, result) {
+		t.Errorf(`Expected %v got %v`, []string{"2", "4", "again"}, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestEntryReplace(t *testing.T) {
+	var p IEntryHashTable = NewEntryHashTable()
+	p.emplace(&Entry{id: "a", name: "one"})
+	p.emplace(&Entry{id: "b", name: "two"})
+
+	p.replace(&Entry{id: "a", name: "one"})
+	if index, ok := p.lookup("a"); !ok || index != 0 {
+		t.Errorf(`Expected key %s at %d got %d`, "a", 0, index)
+	}
+	p.replace(&Entry{id: "b", name: "again"})
+	if index, ok := p.lookup("b"); !ok || index != 2 {
+		t.Errorf(`Expected key %s at %d got %d`, "b", 2, index)
+	}
+	if result := extract(p.getRawData()); !slices.Equal(
+		[]string{"one", "again"}, result) {
+		t.Errorf(`Expected %v got %v`, []string{"one", "again"}, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
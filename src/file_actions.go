@@ -0,0 +1,237 @@
+package dsearch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	clipboardLimit = 8 << 20
+	trashInfoExt   = ".trashinfo"
+	trashInfo      = "[Trash Info]\nPath=%s\nDeletionDate=%s\n"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func fileActions(path string, dir bool) []Action {
+	actions := []Action{
+		{name: "Reveal in File Manager", execute: func() { revealFile(path) }},
+		{name: "Copy Path", execute: func() { copyText(path) }},
+	}
+	if !dir {
+		actions = append(actions, Action{
+			name:    "Copy Contents",
+			execute: func() { copyContents(path) },
+		})
+	}
+	return append(actions,
+		Action{
+			name: "Rename",
+			input: &actionInput{
+				prompt: "Rename to",
+				value:  filepath.Base(path),
+				submit: func(name string) {
+					if err := renameFile(path, name); err != nil {
+						log.Printf(`Failed to rename %s, err: %v`, path, err)
+					}
+				},
+			},
+		},
+		Action{
+			name: "Move to Trash",
+			execute: func() {
+				if err := trashFile(path); err != nil {
+					log.Printf(`Failed to trash %s, err: %v`, path, err)
+				}
+			},
+		})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func revealFile(path string) {
+	// NOTE: dbus-send splits arrays on commas, so they are escaped as well.
+	uri := strings.ReplaceAll(fileURI(path), ",", "%2C")
+	cmd := exec.Command(
+		"dbus-send",
+		"--session",
+		"--print-reply",
+		"--reply-timeout=2000",
+		"--dest=org.freedesktop.FileManager1",
+		"/org/freedesktop/FileManager1",
+		"org.freedesktop.FileManager1.ShowItems",
+		"array:string:"+uri,
+		"string:")
+	if err := cmd.Run(); err != nil {
+		log.Printf(`Failed to reveal %s, err: %v`, path, err)
+		launch("xdg-open", filepath.Dir(path))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func copyText(text string) {
+	if err := clipboard.WriteAll(text); err != nil {
+		log.Printf(`Failed to copy to clipboard, err: %v`, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func copyContents(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf(`Failed to copy %s, err: %v`, path, err)
+		return
+	}
+	if !info.Mode().IsRegular() || info.Size() > clipboardLimit {
+		log.Printf(`Refuse to copy %s of %d bytes`, path, info.Size())
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf(`Failed to copy %s, err: %v`, path, err)
+		return
+	}
+	copyText(string(data))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func renameFile(path, name string) error {
+	if len(name) == 0 || name == "." || name == ".." ||
+		strings.Contains(name, "/") {
+		return fmt.Errorf(`invalid name %q`, name)
+	}
+	target := filepath.Join(filepath.Dir(path), name)
+	if target == path {
+		return nil
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf(`%s already exists`, target)
+	}
+	return os.Rename(path, target)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func device(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.New("no device")
+	}
+	return uint64(stat.Dev), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func trashDir(path string) (dir, recorded string, err error) {
+	// NOTE: Files are moved to the home trash when it is on the same device,
+	// otherwise to the .Trash-$uid directory at the top of their mount where
+	// the path is recorded relative to it. The shared $topdir/.Trash is not
+	// used.
+	dev, err := device(path)
+	if err != nil {
+		return "", "", err
+	}
+	home := filepath.Join(xdgDir("XDG_DATA_HOME", ".local/share"), "Trash")
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return "", "", err
+	}
+	if homeDev, err := device(home); err == nil && homeDev == dev {
+		return home, path, nil
+	}
+
+	top := filepath.Dir(path)
+	for top != filepath.Dir(top) {
+		parentDev, err := device(filepath.Dir(top))
+		if err != nil || parentDev != dev {
+			break
+		}
+		top = filepath.Dir(top)
+	}
+	rel, err := filepath.Rel(top, path)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(top, fmt.Sprintf(`.Trash-%d`, os.Getuid())), rel, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func trashFile(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir, recorded, err := trashDir(path)
+	if err != nil {
+		return err
+	}
+	files, info := filepath.Join(dir, "files"), filepath.Join(dir, "info")
+	for _, d := range []string{files, info} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return err
+		}
+	}
+
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf(`%s.%d`, base, i)
+		}
+		if _, err := os.Lstat(filepath.Join(files, name)); err == nil {
+			continue
+		}
+		infoPath := filepath.Join(info, name+trashInfoExt)
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(
+			f,
+			trashInfo,
+			(&url.URL{Path: recorded}).EscapedPath(),
+			time.Now().Format("2006-01-02T15:04:05"))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(path, filepath.Join(files, name))
+		}
+		if err != nil {
+			os.Remove(infoPath)
+		}
+		return err
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,73 @@
+package dsearch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestRenameFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+	path := filepath.Join(dir, "a.txt")
+
+	for _, name := range []string{"", "..", "sub/c.txt", "b.txt"} {
+		if err := renameFile(path, name); err == nil {
+			t.Errorf(`Expected renaming to %q to fail`, name)
+		}
+	}
+	if err := renameFile(path, "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "c.txt")); err != nil ||
+		string(data) != "a" {
+		t.Errorf(`Expected c.txt to be renamed from a.txt, err: %v`, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestTrashFile(t *testing.T) {
+	data := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", data)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"my notes.txt": "first"})
+	path := filepath.Join(dir, "my notes.txt")
+
+	if err := trashFile(path); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{"my notes.txt": "second"})
+	if err := trashFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf(`Expected %s to be gone, err: %v`, path, err)
+	}
+
+	trash := filepath.Join(data, "Trash")
+	for name, content := range map[string]string{
+		"my notes.txt":   "first",
+		"my notes.txt.2": "second",
+	} {
+		result, err := os.ReadFile(filepath.Join(trash, "files", name))
+		if err != nil || string(result) != content {
+			t.Errorf(`Expected %s got %s, err: %v`, content, result, err)
+		}
+		info, err := os.ReadFile(filepath.Join(trash, "info", name+trashInfoExt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(info), "\n")
+		expected := "Path=" + strings.ReplaceAll(path, " ", "%20")
+		if lines[0] != "[Trash Info]" || lines[1] != expected ||
+			!strings.HasPrefix(lines[2], "DeletionDate=") {
+			t.Errorf(`Unexpected trash info %q`, info)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
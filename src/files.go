@@ -0,0 +1,406 @@
+package dsearch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charlievieth/fastwalk"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+// NOTE: Globs follow the .gitignore syntax, a glob without a slash matches
+// the name at any depth, otherwise the path relative to the root. A trailing
+// slash only matches directories and ** matches any number of directories.
+// Include globs only filter files, directories are always walked unless
+// excluded.
+type RootConfig struct {
+	Path     string   `json:"path"`
+	MaxDepth int      `json:"max_depth"`
+	Follow   bool     `json:"follow_symlinks"`
+	Hidden   bool     `json:"hidden"`
+	NoIgnore bool     `json:"no_ignore"`
+	Include  []string `json:"include"`
+	Exclude  []string `json:"exclude"`
+}
+
+type FilesConfig struct {
+	Roots []RootConfig `json:"roots"`
+}
+
+type globPattern struct {
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+type ignoreRules struct {
+	parent   *ignoreRules
+	dir      string
+	git      bool
+	patterns []globPattern
+}
+
+type fileRoot struct {
+	cfg     RootConfig
+	path    string
+	include []globPattern
+	exclude []globPattern
+	mutex   sync.Mutex
+	rules   map[string]*ignoreRules
+}
+
+// NOTE: Ignore files of the same directory are read in order of precedence,
+// .gitignore only applies within a git repository.
+var ignoreFiles = []string{".gitignore", ".ignore", ".fdignore"}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseGlob(line string) (globPattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(line, `\`) {
+		line += " "
+	}
+	if len(line) == 0 || line[0] == '#' {
+		return globPattern{}, false
+	}
+
+	var glob globPattern
+	if line[0] == '!' {
+		glob.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		glob.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return globPattern{}, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	glob.segments = strings.Split(line, "/")
+	if !anchored {
+		glob.segments = append([]string{"**"}, glob.segments...)
+	}
+	return glob, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseGlobs(lines []string) []globPattern {
+	var globs []globPattern
+	for _, line := range lines {
+		if glob, ok := parseGlob(line); ok {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p globPattern) match(rel string, dir bool) bool {
+	if p.dirOnly && !dir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func matchGlobs(globs []globPattern, rel string, dir bool) bool {
+	for _, glob := range globs {
+		if glob.match(rel, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func expandPath(p string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "/"
+	}
+	p = os.ExpandEnv(p)
+	if p == "~" {
+		return home
+	}
+	if strings.HasPrefix(p, "~/") {
+		return filepath.Join(home, p[2:])
+	}
+	if !filepath.IsAbs(p) {
+		return filepath.Join(home, p)
+	}
+	return filepath.Clean(p)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newFileRoot(cfg RootConfig) *fileRoot {
+	return &fileRoot{
+		cfg:     cfg,
+		path:    expandPath(cfg.Path),
+		include: parseGlobs(cfg.Include),
+		exclude: parseGlobs(cfg.Exclude),
+		rules:   make(map[string]*ignoreRules),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newFileRoots(cfg FilesConfig) []*fileRoot {
+	var roots []*fileRoot
+	for _, root := range cfg.Roots {
+		roots = append(roots, newFileRoot(root))
+	}
+	return roots
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func findRoot(roots []*fileRoot, path string) *fileRoot {
+	var found *fileRoot
+	for _, root := range roots {
+		if (path == root.path || inDirs(path, []string{root.path})) &&
+			(found == nil || len(root.path) > len(found.path)) {
+			found = root
+		}
+	}
+	return found
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func readIgnoreFile(path string) []globPattern {
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf(`Failed to read %s, err: %v`, path, err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return parseGlobs(lines)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileRoot) ignoreRules(dir string) *ignoreRules {
+	p.mutex.Lock()
+	rules, ok := p.rules[dir]
+	p.mutex.Unlock()
+	if ok {
+		return rules
+	}
+
+	rules = &ignoreRules{dir: dir}
+	if dir != p.path && inDirs(dir, []string{p.path}) {
+		rules.parent = p.ignoreRules(filepath.Dir(dir))
+		rules.git = rules.parent.git
+	} else {
+		for parent := dir; !rules.git; parent = filepath.Dir(parent) {
+			_, err := os.Lstat(filepath.Join(parent, ".git"))
+			rules.git = err == nil
+			if parent == filepath.Dir(parent) {
+				break
+			}
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+		rules.git = true
+	}
+	for _, name := range ignoreFiles {
+		if name == ".gitignore" && !rules.git {
+			continue
+		}
+		rules.patterns = append(
+			rules.patterns,
+			readIgnoreFile(filepath.Join(dir, name))...)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if cached, ok := p.rules[dir]; ok {
+		return cached
+	}
+	p.rules[dir] = rules
+	return rules
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *ignoreRules) ignored(path string, dir bool) bool {
+	// NOTE: The last matching pattern wins, and the rules of a directory win
+	// over the ones of its parents.
+	for rules := p; rules != nil; rules = rules.parent {
+		if len(rules.patterns) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(rules.dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for i := len(rules.patterns) - 1; i >= 0; i-- {
+			if rules.patterns[i].match(rel, dir) {
+				return !rules.patterns[i].negate
+			}
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileRoot) depth(path string) int {
+	rel, err := filepath.Rel(p.path, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileRoot) skip(path string, dir bool) bool {
+	rel, err := filepath.Rel(p.path, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if p.cfg.MaxDepth > 0 && p.depth(path) > p.cfg.MaxDepth {
+		return true
+	}
+	if name := filepath.Base(path); !p.cfg.Hidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if matchGlobs(p.exclude, rel, dir) {
+		return true
+	}
+	if !dir && len(p.include) > 0 && !matchGlobs(p.include, rel, dir) {
+		return true
+	}
+	if p.cfg.NoIgnore {
+		return false
+	}
+	return p.ignoreRules(filepath.Dir(path)).ignored(path, dir)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileRoot) walk(
+	ctx context.Context,
+	start string,
+	fn func(path string, dir bool),
+) error {
+	walkFn := func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			log.Printf(`Error during walking %s: %v\n`, path, err)
+			return nil // returning the error stops iteration
+		}
+
+		dir := d.IsDir()
+		if d.Type()&fs.ModeSymlink != 0 && p.cfg.Follow {
+			if info, err := fastwalk.StatDirEntry(path, d); err == nil {
+				dir = info.IsDir()
+			}
+		}
+		if path != start && p.skip(path, dir) {
+			if dir {
+				return fastwalk.SkipDir
+			}
+			return nil
+		}
+		fn(path, dir)
+		if dir && p.cfg.MaxDepth > 0 && p.depth(path) >= p.cfg.MaxDepth {
+			return fastwalk.SkipDir
+		}
+		return nil
+	}
+
+	return fastwalk.Walk(
+		&fastwalk.Config{
+			Follow:  p.cfg.Follow,
+			ToSlash: fastwalk.DefaultToSlash(),
+		},
+		start,
+		fastwalk.IgnorePermissionErrors(walkFn))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadFiles(
+	ctx context.Context,
+	entryChan chan<- *Entry,
+	roots []*fileRoot,
+	w *watcher,
+) bool {
+	ok := true
+	for _, root := range roots {
+		err := root.walk(ctx, root.path, func(path string, dir bool) {
+			if dir && w != nil {
+				w.watch(path)
+			}
+			if !dir {
+				entryChan <- buildFileEntry(path)
+			} else if path != root.path {
+				entryChan <- buildDirEntry(path)
+			}
+		})
+		if err != nil {
+			log.Printf(`Failed to walk %s, err: %v`, root.path, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,168 @@
+package dsearch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestGlobPattern(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		dir      bool
+		expected bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "x/y/a.log", false, true},
+		{"/*.log", "x/a.log", false, false},
+		{"build/", "src/build", true, true},
+		{"build/", "src/build", false, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "x/doc/a.txt", false, false},
+		{"**/doc/*.txt", "x/doc/a.txt", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{`\#notes`, "#notes", false, true},
+	}
+	for _, test := range tests {
+		glob, ok := parseGlob(test.glob)
+		if !ok {
+			t.Errorf(`Failed to parse %s`, test.glob)
+			continue
+		}
+		if result := glob.match(test.path, test.dir); result != test.expected {
+			t.Errorf(`%s on %s: expected %v got %v`,
+				test.glob, test.path, test.expected, result)
+		}
+	}
+	for _, line := range []string{"", "# comment", "/", "!"} {
+		if _, ok := parseGlob(line); ok {
+			t.Errorf(`Expected %q to be skipped`, line)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func walkRoot(t *testing.T, cfg RootConfig) []string {
+	root := newFileRoot(cfg)
+	entryChan := make(chan *Entry)
+	go func() {
+		loadFiles(context.Background(), entryChan, []*fileRoot{root}, nil)
+		close(entryChan)
+	}()
+	var result []string
+	for entry := range entryChan {
+		rel, err := filepath.Rel(root.path, entry.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isDirEntry(entry) {
+			rel += "/"
+		}
+		result = append(result, filepath.ToSlash(rel))
+	}
+	slices.Sort(result)
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestLoadFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "*.txt\n",
+		".ignore":                 "scratch/\n",
+		".config/app.conf":        "",
+		"notes.txt":               "",
+		"scratch/a.md":            "",
+		"deep/er/still/file.md":   "",
+		"repo/.git/":              "",
+		"repo/.gitignore":         "build/\n*.o\n!keep.o\n",
+		"repo/main.go":            "",
+		"repo/main.o":             "",
+		"repo/keep.o":             "",
+		"repo/build/out.bin":      "",
+		"repo/docs/.fdignore":     "draft.md\n",
+		"repo/docs/draft.md":      "",
+		"repo/docs/guide.md":      "",
+		"repo/node_modules/x.js":  "",
+		"repo/vendor/lib/lib.txt": "",
+	})
+
+	result := walkRoot(t, RootConfig{
+		Path:    root,
+		Exclude: []string{"node_modules/"},
+	})
+	expected := []string{
+		"deep/",
+		"deep/er/",
+		"deep/er/still/",
+		"deep/er/still/file.md",
+		"notes.txt",
+		"repo/",
+		"repo/docs/",
+		"repo/docs/guide.md",
+		"repo/keep.o",
+		"repo/main.go",
+		"repo/vendor/",
+		"repo/vendor/lib/",
+		"repo/vendor/lib/lib.txt",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	result = walkRoot(t, RootConfig{
+		Path:     root,
+		MaxDepth: 2,
+		Hidden:   true,
+		NoIgnore: true,
+		Include:  []string{"*.md", "*.conf"},
+	})
+	expected = []string{
+		".config/",
+		".config/app.conf",
+		"deep/",
+		"deep/er/",
+		"repo/",
+		"repo/.git/",
+		"repo/build/",
+		"repo/docs/",
+		"repo/node_modules/",
+		"repo/vendor/",
+		"scratch/",
+		"scratch/a.md",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,147 @@
+package dsearch
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type filterOptions struct {
+	debug     bool
+	json      bool
+	execFirst bool
+	limit     int
+	query     string
+}
+
+// NOTE: One JSON object per line, the score of the entries pinned by their
+// provider is math.MaxInt32 and the entries listed for an empty query have
+// none.
+type filterResult struct {
+	Source    string `json:"source"`
+	ID        string `json:"id"`
+	Value     string `json:"value"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"`
+}
+
+const filterCommand = "filter"
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseFilterOptions(args []string) (*filterOptions, error) {
+	opts := &filterOptions{}
+	flags := flag.NewFlagSet("dsearch filter", flag.ContinueOnError)
+	flags.BoolVar(&opts.debug, "DEBUG", false, "Debug Mode")
+	flags.BoolVar(&opts.json, "json", false,
+		"Print the results as JSON lines with their score and positions")
+	flags.BoolVar(&opts.execFirst, "exec-first", false,
+		"Launch the top result instead of printing the results")
+	flags.IntVar(&opts.limit, "limit", 0, "Print at most this many results")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.debug = opts.debug || len(os.Getenv("DEBUG")) > 0
+	opts.query = strings.Join(flags.Args(), " ")
+	return opts, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func runFilter(args []string, stdout io.Writer) int {
+	opts, err := parseFilterOptions(args)
+	if err != nil {
+		return 2
+	}
+
+	f, err := setupLog(opts.debug)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+
+	cfg := LoadConfig()
+	history := NewHistory(historyPath())
+	nodes, manager := filter(
+		context.Background(),
+		cfg.Search.fzfConfig(),
+		history,
+		NewProviders(cfg),
+		opts.query)
+	if opts.limit > 0 && len(nodes) > opts.limit {
+		nodes = nodes[:opts.limit]
+	}
+	if len(nodes) == 0 {
+		return 1
+	}
+
+	if opts.execFirst {
+		entry := nodes[0]
+		log.Printf(`Select entry %s`, entry.Value())
+		if err := history.Record(entry.ID(), opts.query); err != nil {
+			log.Printf(`Failed to record %s, err: %v`, entry.Value(), err)
+		}
+		manager.Execute(entry)
+		return 0
+	}
+
+	if err := writeResults(stdout, nodes, opts.json); err != nil {
+		log.Printf(`Failed to write results, err: %v`, err)
+		return 1
+	}
+	return 0
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func filter(
+	ctx context.Context,
+	cfg FzfConfig,
+	history IHistory,
+	providers []Provider,
+	query string,
+) ([]EntryNode, IEntryManager) {
+	manager := NewEntryManager(make(SigRefresh), cfg, history, providers...)
+	loadProviders(ctx, manager, providers)
+	return manager.FilterEntry(query), manager
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func writeResults(w io.Writer, nodes []EntryNode, asJSON bool) error {
+	encoder := json.NewEncoder(w)
+	for _, node := range nodes {
+		if !asJSON {
+			if _, err := fmt.Fprintln(w, node.Value()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result := filterResult{
+			Source:    node.Source(),
+			ID:        node.ID(),
+			Value:     node.Value(),
+			Positions: []int{},
+		}
+		if matched, ok := node.(*MatchedEntry); ok {
+			result.Score = matched.Score()
+			if positions := matched.Positions(); positions != nil {
+				result.Positions = positions
+			}
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,74 @@
+package dsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestParseFilterOptions(t *testing.T) {
+	opts, err := parseFilterOptions(
+		[]string{"--json", "-limit", "3", "fire", "fox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.json || opts.execFirst || opts.limit != 3 ||
+		opts.query != "fire fox" {
+		t.Errorf(`Unexpected options %+v`, opts)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFilter(t *testing.T) {
+	cfg := FzfConfig{matcher: MatcherFuzzy, ignoreCase: true}
+	providers := []Provider{
+		&stubProvider{entries: []string{"Firefox", "Files", "Terminal"}},
+		&calculatorProvider{},
+	}
+	history := NewHistory("")
+
+	var out bytes.Buffer
+	nodes, _ := filter(context.Background(), cfg, history, providers, "fi")
+	if err := writeResults(&out, nodes, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Files", "Firefox"}
+	if result := strings.Fields(out.String()); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	out.Reset()
+	nodes, _ = filter(context.Background(), cfg, history, providers, "1+1")
+	if err := writeResults(&out, nodes, true); err != nil {
+		t.Fatal(err)
+	}
+	var result filterResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != SourceCalculator || result.Value != "1+1 = 2" ||
+		result.Positions == nil {
+		t.Errorf(`Unexpected result %+v`, result)
+	}
+
+	out.Reset()
+	nodes, _ = filter(context.Background(), cfg, history, providers, "term")
+	if err := writeResults(&out, nodes, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != "Terminal" || result.Score <= 0 ||
+		!slices.Equal([]int{0, 1, 2, 3}, result.Positions) {
+		t.Errorf(`Unexpected result %+v`, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -1,112 +1,106 @@
 package dsearch
 
 import (
-	"fmt"
 	"sync"
 
-	fzf "github.com/junegunn/fzf/src"
+	"github.com/junegunn/fzf/src/algo"
+	"github.com/junegunn/fzf/src/util"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
 
-type FzfStream chan string
-type found func(string)
-type read func(FzfStream)
+type SortMode int
 
-type IFzfDelegate interface {
-	ExecuteSync(string, found, read)
-	ExecuteAsync(string, found, read) *sync.WaitGroup
-}
+const (
+	SortScore SortMode = 0
+	SortIndex SortMode = 1
+)
+
+const (
+	slab16Size = 100 * 1024
+	slab32Size = 2048
+)
 
 type FzfConfig struct {
-	exact      bool
+	matcher    string
+	extended   bool
 	ignoreCase bool
 	algo       int
+	sort       SortMode
+	priority   []string
+	prefixes   map[string]string
 }
 
-type FzfDelegate struct {
-	cfg FzfConfig
+type fzfMatcher struct {
+	name    string
+	cfg     FzfConfig
+	matchFn algo.Algo
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func NewFzfDelegate(cfg FzfConfig) IFzfDelegate {
-	return &FzfDelegate{cfg: cfg}
+var algoInit sync.Once
+
+func newFzfMatcher(name string, cfg FzfConfig) Matcher {
+	matchFn := algo.FuzzyMatchV2
+	if name == MatcherExact {
+		matchFn = algo.ExactMatchNaive
+	} else if cfg.algo%2 == 1 {
+		matchFn = algo.FuzzyMatchV1
+	}
+	return &fzfMatcher{name: name, cfg: cfg, matchFn: matchFn}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *FzfDelegate) ExecuteSync(q string, f found, r read) {
-	input := make(FzfStream)
-	output := make(FzfStream)
-	var fin sync.WaitGroup
-	fin.Add(1)
-	go func() {
-		for name := range output {
-			f(name)
-		}
-		fin.Done()
-	}()
-	go func() {
-		r(input)
-		close(input)
-	}()
-	p.filter(q, input, output)
-	close(output)
-	fin.Wait()
+func newSlab() *util.Slab {
+	return util.MakeSlab(slab16Size, slab32Size)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *FzfDelegate) ExecuteAsync(q string, f found, r read) *sync.WaitGroup {
+func (p *fzfMatcher) Name() string {
+	return p.name
+}
 
-	input := make(FzfStream)
-	output := make(FzfStream)
-	var fin sync.WaitGroup
-	fin.Add(1)
-	go func() {
-		for name := range output {
-			f(name)
-		}
-		fin.Done()
-	}()
-	go func() {
-		r(input)
-		close(input)
-	}()
-	go func() {
-		p.filter(q, input, output)
-		close(output)
-	}()
-	return &fin
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fzfMatcher) Compile(q string) Pattern {
+	return compileTerms(q, p.cfg.ignoreCase, p.term)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (p *FzfDelegate) filter(q string, i FzfStream, o FzfStream) int {
-	args := []string{
-		"--filter",
-		fmt.Sprintf(`%s`, q),
-		"--no-sort",
-	}
-	if p.cfg.exact {
-		args = append(args, "--exact")
-	}
-	if p.cfg.ignoreCase {
-		args = append(args, "--ignore-case")
-	}
-	if p.cfg.algo%2 == 1 {
-		args = append(args, "--algo")
-		args = append(args, "v1")
-	}
+func (p *fzfMatcher) term(pattern []rune) termFn {
+	return newFzfTerm(p.matchFn, pattern, p.cfg.ignoreCase)
+}
 
-	opts, err := fzf.ParseOptions(true, args)
-	opts.Input = i
-	opts.Output = o
-	if err != nil {
-		return -1
-	}
+///////////////////////////////////////////////////////////////////////////////
+
+func newFzfTerm(matchFn algo.Algo, pattern []rune, ignoreCase bool) termFn {
+	algoInit.Do(func() { algo.Init("default") })
+	return func(
+		text *util.Chars,
+		withPos bool,
+		slab *util.Slab,
+	) (int, []int, bool) {
+		res, pos := matchFn(
+			!ignoreCase, false, true, text, pattern, withPos, slab)
+		if res.Start < 0 {
+			return 0, nil, false
+		}
+		if pos != nil {
+			return res.Score, *pos, true
+		}
 
-	code, _ := fzf.Run(opts)
-	return code
+		var positions []int
+		if withPos {
+			for i := res.Start; i < res.End; i++ {
+				positions = append(positions, i)
+			}
+		}
+		return res.Score, positions, true
+	}
 }
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,271 @@
+package dsearch
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	historyHalfLife   = 7 * 24 * time.Hour
+	historyMaxRecords = 5000
+)
+
+type LaunchRecord struct {
+	ID    string    `json:"id"`
+	Time  time.Time `json:"time"`
+	Query string    `json:"query"`
+}
+
+type IHistory interface {
+	Record(id, query string) error
+	Forget(id string) error
+	Frecency(id string) float64
+	Affinities(query string) map[string]float64
+	Top(n int) []string
+}
+
+type History struct {
+	mutex    sync.Mutex
+	path     string
+	records  []LaunchRecord
+	frecency map[string]float64
+	now      func() time.Time
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func historyPath() string {
+	return filepath.Join(
+		xdgDir("XDG_STATE_HOME", ".local/state"),
+		"dsearch",
+		"history.jsonl")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func NewHistory(path string) IHistory {
+	p := &History{
+		path:     path,
+		frecency: make(map[string]float64),
+		now:      time.Now,
+	}
+	if err := p.load(); err != nil {
+		log.Printf(`Failed to load history %s, err: %v`, path, err)
+	}
+	return p
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) load() error {
+	if len(p.path) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record LaunchRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf(`Skip malformed history record, err: %v`, err)
+			continue
+		}
+		p.records = append(p.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(p.records) > historyMaxRecords {
+		p.records = p.records[len(p.records)-historyMaxRecords:]
+		if err := p.rewrite(); err != nil {
+			return err
+		}
+	}
+	p.recompute()
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) recompute() {
+	clear(p.frecency)
+	now := p.now()
+	for _, record := range p.records {
+		p.frecency[record.ID] += decay(now.Sub(record.Time))
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func decay(age time.Duration) float64 {
+	return math.Exp2(-float64(max(age, 0)) / float64(historyHalfLife))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) rewrite() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := p.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for _, record := range p.records {
+		if err := encoder.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) append(record LaunchRecord) error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) Record(id, query string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	record := LaunchRecord{ID: id, Time: p.now(), Query: query}
+	p.records = append(p.records, record)
+	p.frecency[id] += 1
+	if len(p.path) == 0 {
+		return nil
+	}
+	return p.append(record)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) Forget(id string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	changed := false
+	for i := range p.records {
+		if p.records[i].ID == id && len(p.records[i].Query) > 0 {
+			p.records[i].Query = ""
+			changed = true
+		}
+	}
+	if !changed || len(p.path) == 0 {
+		return nil
+	}
+	return p.rewrite()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) Affinities(query string) map[string]float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	affinities := make(map[string]float64)
+	query = normalizeQuery(query)
+	if len(query) == 0 {
+		return affinities
+	}
+
+	now := p.now()
+	for _, record := range p.records {
+		learned := normalizeQuery(record.Query)
+		if len(learned) == 0 {
+			continue
+		}
+
+		weight := 0.0
+		if strings.HasPrefix(query, learned) {
+			weight = 1
+		} else if strings.HasPrefix(learned, query) {
+			weight = float64(len(query)) / float64(len(learned))
+		}
+		if weight > 0 {
+			affinities[record.ID] += weight * decay(now.Sub(record.Time))
+		}
+	}
+	return affinities
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) Frecency(id string) float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.frecency[id]
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *History) Top(n int) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var ids []string
+	for id := range p.frecency {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(p.frecency[b], p.frecency[a]),
+			cmp.Compare(a, b))
+	})
+	return ids[:min(n, len(ids))]
+}
+
+///////////////////////////////////////////////////////////////////////////////
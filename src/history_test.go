@@ -0,0 +1,108 @@
+package dsearch
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsearch", "history.jsonl")
+	h := NewHistory(path)
+	for _, id := range []string{"a", "b", "b", "c", "b", "c"} {
+		if err := h.Record(id, id); err != nil {
+			t.Fatalf(`Unexpected err %v`, err)
+		}
+	}
+
+	expected := []string{"b", "c", "a"}
+	if result := h.Top(5); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	h = NewHistory(path)
+	if result := h.Top(2); !slices.Equal(expected[:2], result) {
+		t.Errorf(`Expected %v got %v`, expected[:2], result)
+	}
+	if f := h.Frecency("b"); f < 2.9 || f > 3 {
+		t.Errorf(`Expected frecency ~%d got %f`, 3, f)
+	}
+	if f := h.Frecency("missing"); f != 0 {
+		t.Errorf(`Expected frecency %d got %f`, 0, f)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestHistoryDecay(t *testing.T) {
+	now := time.Now()
+	h := &History{
+		frecency: make(map[string]float64),
+		now:      func() time.Time { return now },
+		records: []LaunchRecord{
+			{ID: "old", Time: now.Add(-4 * historyHalfLife)},
+			{ID: "old", Time: now.Add(-4 * historyHalfLife)},
+			{ID: "old", Time: now.Add(-4 * historyHalfLife)},
+			{ID: "new", Time: now.Add(-historyHalfLife)},
+		},
+	}
+	h.recompute()
+
+	if f := h.Frecency("new"); f != 0.5 {
+		t.Errorf(`Expected frecency %f got %f`, 0.5, f)
+	}
+	expected := []string{"new", "old"}
+	if result := h.Top(2); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestHistoryAffinities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewHistory(path)
+	h.Record("firefox", "FF")
+	h.Record("ffmpeg", "ffmpeg")
+	h.Record("files", "")
+
+	cases := []struct {
+		query    string
+		expected map[string]float64
+	}{
+		{"ff", map[string]float64{"firefox": 1, "ffmpeg": 1.0 / 3}},
+		{"ff x", map[string]float64{"firefox": 1}},
+		{"f", map[string]float64{"firefox": 0.5, "ffmpeg": 1.0 / 6}},
+		{"x", map[string]float64{}},
+		{"", map[string]float64{}},
+	}
+	for _, c := range cases {
+		result := h.Affinities(c.query)
+		if len(result) != len(c.expected) {
+			t.Errorf(`%s: Expected %v got %v`, c.query, c.expected, result)
+			continue
+		}
+		for id, expected := range c.expected {
+			if math.Abs(result[id]-expected) > 1e-6 {
+				t.Errorf(`%s: Expected %v got %v`, c.query, c.expected, result)
+			}
+		}
+	}
+
+	if err := h.Forget("firefox"); err != nil {
+		t.Fatalf(`Unexpected err %v`, err)
+	}
+	h = NewHistory(path)
+	if _, ok := h.Affinities("ff")["firefox"]; ok {
+		t.Errorf(`Expected %s to be forgotten`, "firefox")
+	}
+	if f := h.Frecency("firefox"); f == 0 {
+		t.Errorf(`Expected frecency of %s to be kept`, "firefox")
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
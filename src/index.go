@@ -0,0 +1,150 @@
+package dsearch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type IndexedProvider interface {
+	Provider
+	Restore(record IndexRecord) *Entry
+}
+
+type IndexRecord struct {
+	ID       string
+	Source   string
+	Name     string
+	Subtitle string
+	Icon     string
+	Path     string
+	Keywords []string
+}
+
+// NOTE: The index is a fixed header followed by the gob encoded records:
+//
+//	magic[4] version[4] checksum[4] length[8] records[length]
+//
+// The checksum is the CRC-32 of the records, a snapshot of another version
+// or with a mismatching checksum is dropped.
+type indexHeader struct {
+	Magic    [4]byte
+	Version  uint32
+	Checksum uint32
+	Length   uint64
+}
+
+const indexVersion = 1
+
+var indexMagic = [4]byte{'D', 'S', 'I', 'X'}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func indexPath() string {
+	return filepath.Join(xdgDir("XDG_CACHE_HOME", ".cache"), "dsearch", "index")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newIndexRecord(node EntryNode) IndexRecord {
+	return IndexRecord{
+		ID:       node.ID(),
+		Source:   node.Source(),
+		Name:     node.Value(),
+		Subtitle: node.Subtitle(),
+		Icon:     node.Icon(),
+		Path:     node.Path(),
+		Keywords: node.Keywords(),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func sameEntry(a, b EntryNode) bool {
+	return a.Value() == b.Value() &&
+		a.Subtitle() == b.Subtitle() &&
+		a.Icon() == b.Icon() &&
+		a.Path() == b.Path() &&
+		slices.Equal(a.Keywords(), b.Keywords())
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func readIndex(path string) ([]IndexRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var header indexHeader
+	reader := bytes.NewReader(data)
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != indexMagic {
+		return nil, errors.New("not an index")
+	}
+	if header.Version != indexVersion {
+		return nil, fmt.Errorf("index version %d, expected %d",
+			header.Version, indexVersion)
+	}
+	payload := data[len(data)-reader.Len():]
+	if uint64(len(payload)) != header.Length ||
+		crc32.ChecksumIEEE(payload) != header.Checksum {
+		return nil, errors.New("corrupted index")
+	}
+
+	var records []IndexRecord
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func writeIndex(path string, records []IndexRecord) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(records); err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	header := indexHeader{
+		Magic:    indexMagic,
+		Version:  indexVersion,
+		Checksum: crc32.ChecksumIEEE(payload.Bytes()),
+		Length:   uint64(payload.Len()),
+	}
+	if err := binary.Write(&data, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	data.Write(payload.Bytes())
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,109 @@
+package dsearch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type indexedProvider struct {
+	entries map[string]string
+	order   []string
+}
+
+func (p *indexedProvider) Name() string { return "indexed" }
+
+func (p *indexedProvider) Load(_ context.Context, sink chan<- *Entry) {
+	for _, id := range p.order {
+		sink <- &Entry{id: id, source: "indexed", name: p.entries[id]}
+	}
+}
+
+func (p *indexedProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Stub")
+}
+
+func (p *indexedProvider) Restore(record IndexRecord) *Entry {
+	return &Entry{id: record.ID, source: record.Source, name: record.Name}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestIndexFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	records := []IndexRecord{
+		{ID: "a", Source: SourceFiles, Name: "a", Path: "/a"},
+		{ID: "b", Source: SourceApplications, Keywords: []string{"x", "y"}},
+	}
+	if err := writeIndex(path, records); err != nil {
+		t.Fatal(err)
+	}
+	result, err := readIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Path != "/a" ||
+		!slices.Equal(records[1].Keywords, result[1].Keywords) {
+		t.Errorf(`Expected %v got %v`, records, result)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := slices.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xff
+	versioned := slices.Clone(data)
+	versioned[4]++
+	for _, data := range [][]byte{corrupted, versioned, data[:10]} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readIndex(path); err == nil {
+			t.Errorf(`Expected an error reading %v`, data)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestLoadEntriesIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index")
+	provider := &indexedProvider{
+		entries: map[string]string{"1": "one", "2": "two", "3": "three"},
+		order:   []string{"1", "2", "3"},
+	}
+	manager := NewEntryManager(nil, dummyCfg, nil, provider)
+	manager.SetIndex(path)
+	manager.LoadEntries(func(c chan *Entry) { provider.Load(context.Background(), c) })
+	if err := manager.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	provider = &indexedProvider{
+		entries: map[string]string{"1": "one", "3": "drei", "4": "four"},
+		order:   []string{"1", "3", "4"},
+	}
+	manager = NewEntryManager(nil, dummyCfg, nil, provider)
+	manager.SetIndex(path)
+	var restored []string
+	manager.LoadEntries(func(c chan *Entry) {
+		restored = extract(manager.FilterEntry(""))
+		provider.Load(context.Background(), c)
+	})
+
+	expected := []string{"one", "two", "three"}
+	if !slices.Equal(expected, restored) {
+		t.Errorf(`Expected %v got %v`, expected, restored)
+	}
+	expected = []string{"one", "drei", "four"}
+	if result := extract(manager.FilterEntry("")); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
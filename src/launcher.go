@@ -0,0 +1,280 @@
+package dsearch
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"code.rocketnine.space/tslocum/desktop"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func startDetached(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Foreground: false,
+		Setsid:     true,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func launch(name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	if err := startDetached(cmd); err != nil {
+		log.Printf(`Failed to exec %s, err:%v`, cmd.String(), err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func launchTerminal(dir string, cfg LauncherConfig) {
+	if len(cfg.Terminal) == 0 {
+		log.Printf(`Failed to open %s, err: no terminal configured`, dir)
+		return
+	}
+	cmd := exec.Command(cfg.Terminal[0])
+	cmd.Dir = dir
+	if err := startDetached(cmd); err != nil {
+		log.Printf(`Failed to exec %s, err:%v`, cmd.String(), err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func launchDesktopEntry(
+	path string,
+	entry *desktop.Entry,
+	cfg LauncherConfig,
+	files ...string,
+) {
+	if cfg.Backend == LauncherGio {
+		launch("gio", append([]string{"launch", path}, files...)...)
+		return
+	}
+
+	for _, files := range splitFiles(entry.Exec, files) {
+		cmd, err := buildDesktopCommand(path, entry, cfg, files...)
+		if err != nil {
+			log.Printf(`Failed to build command for %s, err:%v`, path, err)
+			return
+		}
+		if err := startDetached(cmd); err != nil {
+			log.Printf(`Failed to exec %s, err:%v`, cmd.String(), err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func launchDesktopFile(path, action string, cfg LauncherConfig) {
+	file, err := readDesktopFile(path)
+	if err != nil {
+		log.Printf(`Failed to parse file %s, err %v`, path, err)
+		return
+	}
+	entry := file.entry()
+	if len(action) == 0 {
+		launchDesktopEntry(path, entry, cfg)
+		return
+	}
+	for _, desktopAction := range file.actions() {
+		if desktopAction.id == action {
+			launchDesktopAction(path, entry, desktopAction, cfg)
+			return
+		}
+	}
+	log.Printf(`Action %s not found in %s`, action, path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func launchDesktopAction(
+	path string,
+	entry *desktop.Entry,
+	action desktopAction,
+	cfg LauncherConfig,
+	files ...string,
+) {
+	// NOTE: gio cannot activate actions, so they are always launched natively.
+	actionEntry := *entry
+	actionEntry.Exec = action.exec
+	if len(action.icon) > 0 {
+		actionEntry.Icon = action.icon
+	}
+
+	for _, files := range splitFiles(actionEntry.Exec, files) {
+		cmd, err := buildDesktopCommand(path, &actionEntry, cfg, files...)
+		if err != nil {
+			log.Printf(
+				`Failed to build action %s for %s, err:%v`,
+				action.id,
+				path,
+				err)
+			return
+		}
+		if err := startDetached(cmd); err != nil {
+			log.Printf(`Failed to exec %s, err:%v`, cmd.String(), err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func buildDesktopCommand(
+	path string,
+	entry *desktop.Entry,
+	cfg LauncherConfig,
+	files ...string,
+) (*exec.Cmd, error) {
+	argv, err := expandExec(entry.Exec, entry, path, files)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Terminal {
+		if len(cfg.Terminal) == 0 {
+			return nil, fmt.Errorf(`no terminal configured`)
+		}
+		argv = append(append([]string{}, cfg.Terminal...), argv...)
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = entry.Path
+	return cmd, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func splitExec(line string) ([]string, error) {
+	var args []string
+	var sb strings.Builder
+	inArg, quoted := false, false
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quoted && r == '\\' && i+1 < len(runes):
+			i++
+			sb.WriteRune(runes[i])
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf(`unterminated quote in %q`, line)
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func fieldCodes(line string) map[rune]bool {
+	codes := make(map[rune]bool)
+	runes := []rune(line)
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] == '%' {
+			i++
+			codes[runes[i]] = true
+		}
+	}
+	return codes
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func splitFiles(line string, files []string) [][]string {
+	codes := fieldCodes(line)
+	if len(files) <= 1 || codes['F'] || codes['U'] ||
+		!codes['f'] && !codes['u'] {
+		return [][]string{files}
+	}
+	var groups [][]string
+	for _, file := range files {
+		groups = append(groups, []string{file})
+	}
+	return groups
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func expandExec(
+	line string,
+	entry *desktop.Entry,
+	path string,
+	files []string,
+) ([]string, error) {
+	tokens, err := splitExec(line)
+	if err != nil {
+		return nil, err
+	}
+
+	var argv []string
+	for _, token := range tokens {
+		switch token {
+		case "%F", "%U":
+			argv = append(argv, files...)
+			continue
+		case "%i":
+			if len(entry.Icon) > 0 {
+				argv = append(argv, "--icon", entry.Icon)
+			}
+			continue
+		}
+
+		var sb strings.Builder
+		codes, literals := 0, 0
+		runes := []rune(token)
+		for i := 0; i < len(runes); i++ {
+			if runes[i] != '%' || i+1 >= len(runes) {
+				sb.WriteRune(runes[i])
+				literals++
+				continue
+			}
+			i++
+			codes++
+			switch runes[i] {
+			case '%':
+				sb.WriteRune('%')
+				literals++
+			case 'f', 'u':
+				if len(files) > 0 {
+					sb.WriteString(files[0])
+				}
+			case 'c':
+				sb.WriteString(entry.Name)
+			case 'k':
+				sb.WriteString(path)
+			}
+		}
+		if arg := sb.String(); len(arg) > 0 || codes == 0 || literals > 0 {
+			argv = append(argv, arg)
+		}
+	}
+
+	if len(argv) == 0 {
+		return nil, fmt.Errorf(`empty Exec line`)
+	}
+	return argv, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
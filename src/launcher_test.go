@@ -0,0 +1,99 @@
+package dsearch
+
+import (
+	"slices"
+	"testing"
+
+	"code.rocketnine.space/tslocum/desktop"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestExpandExec(t *testing.T) {
+	entry := &desktop.Entry{Name: "Vim", Icon: "gvim"}
+	path := "/usr/share/applications/vim.desktop"
+	cases := []struct {
+		line     string
+		files    []string
+		expected []string
+	}{
+		{`vim %F`, nil, []string{"vim"}},
+		{`vim %F`, []string{"a", "b"}, []string{"vim", "a", "b"}},
+		{`vim --file=%f`, []string{"a", "b"}, []string{"vim", "--file=a"}},
+		{`vim %i %c %k`, nil, []string{"vim", "--icon", "gvim", "Vim", path}},
+		{`"/opt/my app/run" "a \"b\"" 100%%`, nil,
+			[]string{"/opt/my app/run", `a "b"`, "100%"}},
+		{`sh -c "echo \$HOME"`, nil, []string{"sh", "-c", "echo $HOME"}},
+		{`app "" %f %c`, nil, []string{"app", "", "Vim"}},
+		{`app --file=%f`, nil, []string{"app", "--file="}},
+	}
+	for _, c := range cases {
+		result, err := expandExec(c.line, entry, path, c.files)
+		if err != nil {
+			t.Errorf(`%s: unexpected err %v`, c.line, err)
+		} else if !slices.Equal(c.expected, result) {
+			t.Errorf(`%s: Expected %q got %q`, c.line, c.expected, result)
+		}
+	}
+
+	if _, err := expandExec(`vim "unterminated`, entry, path, nil); err == nil {
+		t.Errorf(`Expected error for unterminated quote`)
+	}
+	if _, err := expandExec(`%f`, entry, path, nil); err == nil {
+		t.Errorf(`Expected error for empty Exec line`)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestSplitFiles(t *testing.T) {
+	files := []string{"a", "b"}
+	cases := []struct {
+		line     string
+		files    []string
+		expected [][]string
+	}{
+		{`vim %f`, files, [][]string{{"a"}, {"b"}}},
+		{`vim --file=%u`, files, [][]string{{"a"}, {"b"}}},
+		{`vim %F`, files, [][]string{files}},
+		{`vim`, files, [][]string{files}},
+		{`vim 100%%f`, files, [][]string{files}},
+		{`vim %f`, []string{"a"}, [][]string{{"a"}}},
+		{`vim %f`, nil, [][]string{nil}},
+	}
+	for _, c := range cases {
+		result := splitFiles(c.line, c.files)
+		if !slices.EqualFunc(c.expected, result, slices.Equal) {
+			t.Errorf(`%s: Expected %q got %q`, c.line, c.expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestBuildDesktopCommand(t *testing.T) {
+	cfg := LauncherConfig{
+		Backend:  LauncherNative,
+		Terminal: []string{"foot", "-e"},
+	}
+	entry := &desktop.Entry{
+		Name:     "Htop",
+		Exec:     "htop %U",
+		Path:     "/tmp",
+		Terminal: true,
+	}
+	cmd, err := buildDesktopCommand("htop.desktop", entry, cfg)
+	if err != nil {
+		t.Fatalf(`Unexpected err %v`, err)
+	}
+
+	expected := []string{"foot", "-e", "htop"}
+	if !slices.Equal(expected, cmd.Args) {
+		t.Errorf(`Expected %q got %q`, expected, cmd.Args)
+	}
+	if cmd.Dir != "/tmp" {
+		t.Errorf(`Expected dir %s got %s`, "/tmp", cmd.Dir)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
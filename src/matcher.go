@@ -0,0 +1,411 @@
+package dsearch
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type Pattern interface {
+	Match(text *util.Chars, withPos bool, slab *util.Slab) (int, []int, bool)
+}
+
+type Matcher interface {
+	Name() string
+	Compile(query string) Pattern
+}
+
+const (
+	MatcherFuzzy  = "fuzzy"
+	MatcherExact  = "exact"
+	MatcherPrefix = "prefix"
+	MatcherRegexp = "regexp"
+	MatcherTypo   = "typo"
+)
+
+const (
+	scoreMatch    = 16
+	bonusBoundary = 8
+	penaltyTypo   = 24
+	penaltySkip   = 2
+)
+
+var matcherNames = []string{
+	MatcherFuzzy,
+	MatcherExact,
+	MatcherPrefix,
+	MatcherRegexp,
+	MatcherTypo,
+}
+
+type termFn func(*util.Chars, bool, *util.Slab) (int, []int, bool)
+
+type termPattern []termFn
+
+type wordSpan struct{ start, end int }
+
+type prefixMatcher struct{ cfg FzfConfig }
+
+type regexpMatcher struct{ cfg FzfConfig }
+
+type regexpPattern struct{ re *regexp.Regexp }
+
+type typoMatcher struct{ cfg FzfConfig }
+
+///////////////////////////////////////////////////////////////////////////////
+
+func NewMatcher(cfg FzfConfig) Matcher {
+	switch cfg.matcher {
+	case "", MatcherFuzzy:
+		return newFzfMatcher(MatcherFuzzy, cfg)
+	case MatcherExact:
+		return newFzfMatcher(MatcherExact, cfg)
+	case MatcherPrefix:
+		return &prefixMatcher{cfg: cfg}
+	case MatcherRegexp:
+		return &regexpMatcher{cfg: cfg}
+	case MatcherTypo:
+		return &typoMatcher{cfg: cfg}
+	default:
+		log.Printf(`Unknown matcher %s, fall back to fuzzy`, cfg.matcher)
+		return newFzfMatcher(MatcherFuzzy, cfg)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func nextMatcher(name string) string {
+	i := slices.Index(matcherNames, name)
+	return matcherNames[(i+1)%len(matcherNames)]
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p termPattern) Match(
+	text *util.Chars,
+	withPos bool,
+	slab *util.Slab,
+) (int, []int, bool) {
+	score := 0
+	var positions []int
+	for _, term := range p {
+		s, pos, ok := term(text, withPos, slab)
+		if !ok {
+			return 0, nil, false
+		}
+		score += s
+		positions = append(positions, pos...)
+	}
+	if withPos {
+		positions = compactPositions(positions)
+	}
+	return score, positions, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func compactPositions(positions []int) []int {
+	slices.Sort(positions)
+	return slices.Compact(positions)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func compileTerms(q string, ignoreCase bool, term func([]rune) termFn) Pattern {
+	var terms termPattern
+	for _, field := range strings.Fields(q) {
+		if ignoreCase {
+			field = strings.ToLower(field)
+		}
+		terms = append(terms, term([]rune(field)))
+	}
+	return terms
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func foldRunes(text *util.Chars, ignoreCase bool) []rune {
+	runes := text.ToRunes()
+	if !ignoreCase {
+		return runes
+	}
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+	}
+	return folded
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func splitWords(runes []rune) []wordSpan {
+	var words []wordSpan
+	start := -1
+	for i, r := range runes {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if start >= 0 && (!isWord || isWordBoundary(runes[i-1], r)) {
+			words = append(words, wordSpan{start, i})
+			start = -1
+		}
+		if isWord && start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordSpan{start, len(runes)})
+	}
+	return words
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func isWordBoundary(prev, r rune) bool {
+	return unicode.IsLower(prev) && unicode.IsUpper(r) ||
+		unicode.IsLetter(prev) && unicode.IsDigit(r) ||
+		unicode.IsDigit(prev) && unicode.IsLetter(r)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *prefixMatcher) Name() string {
+	return MatcherPrefix
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *prefixMatcher) Compile(q string) Pattern {
+	return compileTerms(q, p.cfg.ignoreCase, p.term)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *prefixMatcher) term(pattern []rune) termFn {
+	return func(
+		text *util.Chars,
+		withPos bool,
+		_ *util.Slab,
+	) (int, []int, bool) {
+		words := splitWords(text.ToRunes())
+		runes := foldRunes(text, p.cfg.ignoreCase)
+		return matchWordPrefixes(pattern, runes, words, withPos)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func matchWordPrefixes(
+	pattern, runes []rune,
+	words []wordSpan,
+	withPos bool,
+) (int, []int, bool) {
+	// NOTE: The pattern is consumed by prefixes of words in order, so both
+	// "vsc" and "visco" match Visual Studio Code. Longer prefixes are tried
+	// first and failed states are never retried.
+	type chunk struct{ word, length int }
+	var path []chunk
+	failed := make([]bool, (len(pattern)+1)*(len(words)+1))
+
+	var walk func(pi, wi int) bool
+	walk = func(pi, wi int) bool {
+		if pi == len(pattern) {
+			return true
+		}
+		if failed[pi*(len(words)+1)+wi] {
+			return false
+		}
+		for w := wi; w < len(words); w++ {
+			word, k := words[w], 0
+			for pi+k < len(pattern) && word.start+k < word.end &&
+				pattern[pi+k] == runes[word.start+k] {
+				k++
+			}
+			for ; k > 0; k-- {
+				if walk(pi+k, w+1) {
+					path = append(path, chunk{w, k})
+					return true
+				}
+			}
+		}
+		failed[pi*(len(words)+1)+wi] = true
+		return false
+	}
+	if len(pattern) == 0 || !walk(0, 0) {
+		return 0, nil, false
+	}
+
+	score, last := 0, -1
+	var positions []int
+	for i := len(path) - 1; i >= 0; i-- {
+		c := path[i]
+		score += c.length*scoreMatch + bonusBoundary
+		score -= (c.word - last - 1) * penaltySkip
+		if c.word == 0 {
+			score += bonusBoundary
+		}
+		last = c.word
+		if withPos {
+			for k := range c.length {
+				positions = append(positions, words[c.word].start+k)
+			}
+		}
+	}
+	return score, positions, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *regexpMatcher) Name() string {
+	return MatcherRegexp
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *regexpMatcher) Compile(q string) Pattern {
+	expr := strings.TrimSpace(q)
+	if p.cfg.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		log.Printf(`Invalid regexp %s, err: %v`, q, err)
+		expr = regexp.QuoteMeta(strings.TrimSpace(q))
+		if p.cfg.ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re = regexp.MustCompile(expr)
+	}
+	return &regexpPattern{re: re}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *regexpPattern) Match(
+	text *util.Chars,
+	withPos bool,
+	_ *util.Slab,
+) (int, []int, bool) {
+	s := text.ToString()
+	loc := p.re.FindStringIndex(s)
+	if loc == nil {
+		return 0, nil, false
+	}
+
+	start := utf8.RuneCountInString(s[:loc[0]])
+	length := utf8.RuneCountInString(s[loc[0]:loc[1]])
+	score := length * scoreMatch
+	if start == 0 {
+		score += bonusBoundary
+	}
+
+	var positions []int
+	if withPos {
+		for i := range length {
+			positions = append(positions, start+i)
+		}
+	}
+	return score, positions, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *typoMatcher) Name() string {
+	return MatcherTypo
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *typoMatcher) Compile(q string) Pattern {
+	return compileTerms(q, p.cfg.ignoreCase, p.term)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func maxTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *typoMatcher) term(pattern []rune) termFn {
+	return func(
+		text *util.Chars,
+		withPos bool,
+		_ *util.Slab,
+	) (int, []int, bool) {
+		runes := foldRunes(text, p.cfg.ignoreCase)
+		typos, start, end := approximateMatch(pattern, runes)
+		if typos > maxTypos(len(pattern)) {
+			return 0, nil, false
+		}
+
+		score := (len(pattern)-typos)*scoreMatch - typos*penaltyTypo
+		if start == 0 || !unicode.IsLetter(runes[start-1]) &&
+			!unicode.IsDigit(runes[start-1]) {
+			score += bonusBoundary
+		}
+
+		var positions []int
+		if withPos {
+			for i := start; i < end; i++ {
+				positions = append(positions, i)
+			}
+		}
+		return max(score, 1), positions, true
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func approximateMatch(pattern, text []rune) (int, int, int) {
+	// NOTE: Sellers' algorithm, the edit distance between the pattern and the
+	// closest substring of text, with the start of the substring carried along
+	// each column.
+	m := len(pattern)
+	dist := make([]int, m+1)
+	from := make([]int, m+1)
+	next := make([]int, m+1)
+	nextFrom := make([]int, m+1)
+	for i := range dist {
+		dist[i] = i
+	}
+
+	best, bestStart, bestEnd := dist[m], 0, 0
+	for j, r := range text {
+		next[0], nextFrom[0] = 0, j+1
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if pattern[i-1] == r {
+				cost = 0
+			}
+			next[i], nextFrom[i] = dist[i-1]+cost, from[i-1]
+			if dist[i]+1 < next[i] {
+				next[i], nextFrom[i] = dist[i]+1, from[i]
+			}
+			if next[i-1]+1 < next[i] {
+				next[i], nextFrom[i] = next[i-1]+1, nextFrom[i-1]
+			}
+		}
+		dist, next = next, dist
+		from, nextFrom = nextFrom, from
+		if dist[m] < best {
+			best, bestStart, bestEnd = dist[m], from[m], j+1
+		}
+	}
+	return best, bestStart, bestEnd
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,112 @@
+package dsearch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func matchAll(matcher Matcher, query string, texts []string) []string {
+	var result []string
+	pattern := matcher.Compile(query)
+	for _, text := range texts {
+		chars := util.ToChars([]byte(text))
+		if _, _, ok := pattern.Match(&chars, false, newSlab()); ok {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestMatchers(t *testing.T) {
+	texts := []string{
+		"Visual Studio Code",
+		"Firefox",
+		"LibreOffice Calc",
+		"fire.txt",
+		"vim",
+	}
+	tests := []struct {
+		matcher  string
+		query    string
+		expected []string
+	}{
+		{MatcherFuzzy, "vsc", []string{"Visual Studio Code"}},
+		{MatcherFuzzy, "ffx", []string{"Firefox"}},
+		{MatcherExact, "fire", []string{"Firefox", "fire.txt"}},
+		{MatcherExact, "ffx", nil},
+		{MatcherPrefix, "vsc", []string{"Visual Studio Code"}},
+		{MatcherPrefix, "visco", []string{"Visual Studio Code"}},
+		{MatcherPrefix, "loc", []string{"LibreOffice Calc"}},
+		{MatcherPrefix, "ode", nil},
+		{MatcherPrefix, "fi txt", []string{"fire.txt"}},
+		{MatcherRegexp, "^fire", []string{"Firefox", "fire.txt"}},
+		{MatcherRegexp, `\.txt$`, []string{"fire.txt"}},
+		{MatcherRegexp, "fire(", nil},
+		{MatcherTypo, "firfox", []string{"Firefox"}},
+		{MatcherTypo, "stdio", []string{"Visual Studio Code"}},
+		{MatcherTypo, "vin", nil},
+		{MatcherTypo, "vim", []string{"vim"}},
+	}
+	for _, test := range tests {
+		matcher := NewMatcher(FzfConfig{matcher: test.matcher, ignoreCase: true})
+		if name := matcher.Name(); name != test.matcher {
+			t.Errorf(`Expected %s got %s`, test.matcher, name)
+		}
+		result := matchAll(matcher, test.query, texts)
+		if !slices.Equal(test.expected, result) {
+			t.Errorf(`%s %q: Expected %v got %v`,
+				test.matcher, test.query, test.expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestMatcherPositions(t *testing.T) {
+	tests := []struct {
+		matcher  string
+		query    string
+		text     string
+		expected []int
+	}{
+		{MatcherPrefix, "vsc", "Visual Studio Code", []int{0, 7, 14}},
+		{MatcherPrefix, "visco", "Visual Studio Code", []int{0, 1, 2, 14, 15}},
+		{MatcherPrefix, "gi", "openGitHub", []int{4, 5}},
+		{MatcherRegexp, "s.u", "Visual Studio Code", []int{7, 8, 9}},
+		{MatcherRegexp, "ö.k", "Gnome Schlöcke", []int{10, 11, 12}},
+		{MatcherTypo, "firfox", "Firefox", []int{0, 1, 2, 3, 4, 5, 6}},
+	}
+	for _, test := range tests {
+		matcher := NewMatcher(FzfConfig{matcher: test.matcher, ignoreCase: true})
+		chars := util.ToChars([]byte(test.text))
+		_, positions, ok := matcher.Compile(test.query).Match(
+			&chars, true, newSlab())
+		if !ok || !slices.Equal(test.expected, positions) {
+			t.Errorf(`%s %q: Expected %v got %v`,
+				test.matcher, test.query, test.expected, positions)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestNextMatcher(t *testing.T) {
+	name := MatcherFuzzy
+	for range matcherNames {
+		name = nextMatcher(name)
+	}
+	if name != MatcherFuzzy {
+		t.Errorf(`Expected %s got %s`, MatcherFuzzy, name)
+	}
+	if name := NewMatcher(FzfConfig{matcher: "unknown"}).Name(); name != MatcherFuzzy {
+		t.Errorf(`Expected %s got %s`, MatcherFuzzy, name)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,419 @@
+package dsearch
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"code.rocketnine.space/tslocum/desktop"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type mimeDB struct {
+	literals map[string][]mimeGlob
+	suffixes map[string][]mimeGlob
+	globs    []mimeGlob
+	aliases  map[string]string
+	parents  map[string][]string
+}
+
+type mimeGlob struct {
+	weight    int
+	mimeType  string
+	pattern   string
+	sensitive bool
+}
+
+// NOTE: The associations of the mimeapps.list files, in order of precedence.
+// Removed associations hide the ones of the files of lower precedence and of
+// the desktop entries.
+type mimeApps struct {
+	defaults map[string][]string
+	added    map[string][]string
+	removed  map[string][]string
+}
+
+const (
+	mimeDefaultWeight = 50
+	mimeNoGlobs       = "__NOGLOBS__"
+	mimeText          = "text/plain"
+	mimeBinary        = "application/octet-stream"
+	mimeSniffSize     = 512
+
+	mimeDefaultGroup = "Default Applications"
+	mimeAddedGroup   = "Added Associations"
+	mimeRemovedGroup = "Removed Associations"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func dataDirs() []string {
+	var dirs []string
+	for _, dir := range desktop.DataDirs() {
+		dirs = append(dirs, filepath.Dir(dir))
+	}
+	return dirs
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func configDirs() []string {
+	dirs := []string{xdgDir("XDG_CONFIG_HOME", ".config")}
+	setting := os.Getenv("XDG_CONFIG_DIRS")
+	if len(setting) == 0 {
+		setting = "/etc/xdg"
+	}
+	for _, dir := range strings.Split(setting, ":") {
+		if dir = strings.TrimSpace(dir); len(dir) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func readLines(path string, fn func(line string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf(`Failed to read %s, err: %v`, path, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 && line[0] != '#' {
+			fn(line)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseMimeGlob(line string, v2 bool) (mimeGlob, bool) {
+	// NOTE: globs2 lines are weight:type:pattern[:flags], the legacy globs
+	// lines type:pattern.
+	glob := mimeGlob{weight: mimeDefaultWeight}
+	fields := strings.Split(line, ":")
+	if v2 {
+		if len(fields) < 3 {
+			return glob, false
+		}
+		weight, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return glob, false
+		}
+		glob.weight = weight
+		glob.sensitive = len(fields) > 3 && slices.Contains(
+			strings.Split(fields[3], ","), "cs")
+		fields = fields[1:3]
+	} else if len(fields) != 2 {
+		return glob, false
+	}
+	glob.mimeType, glob.pattern = fields[0], fields[1]
+	if !glob.sensitive && glob.pattern != mimeNoGlobs {
+		glob.pattern = strings.ToLower(glob.pattern)
+	}
+	return glob, len(glob.mimeType) > 0 && len(glob.pattern) > 0
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadMimeDB(dirs []string) *mimeDB {
+	db := &mimeDB{
+		literals: make(map[string][]mimeGlob),
+		suffixes: make(map[string][]mimeGlob),
+		aliases:  make(map[string]string),
+		parents:  make(map[string][]string),
+	}
+
+	// NOTE: dirs are ordered by precedence, __NOGLOBS__ drops the globs of
+	// the type from the directories of lower precedence.
+	noGlobs := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.Join(dir, "mime")
+		var globs []mimeGlob
+		parse := func(v2 bool) func(string) {
+			return func(line string) {
+				if glob, ok := parseMimeGlob(line, v2); ok {
+					globs = append(globs, glob)
+				}
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "globs2")); err == nil {
+			readLines(filepath.Join(dir, "globs2"), parse(true))
+		} else {
+			readLines(filepath.Join(dir, "globs"), parse(false))
+		}
+
+		var cleared []string
+		for _, glob := range globs {
+			if noGlobs[glob.mimeType] {
+				continue
+			}
+			if glob.pattern == mimeNoGlobs {
+				cleared = append(cleared, glob.mimeType)
+				continue
+			}
+			db.addGlob(glob)
+		}
+		for _, mimeType := range cleared {
+			noGlobs[mimeType] = true
+		}
+
+		readLines(filepath.Join(dir, "aliases"), func(line string) {
+			alias, mimeType, ok := strings.Cut(line, " ")
+			if _, found := db.aliases[alias]; ok && !found {
+				db.aliases[alias] = mimeType
+			}
+		})
+		readLines(filepath.Join(dir, "subclasses"), func(line string) {
+			mimeType, parent, ok := strings.Cut(line, " ")
+			if ok && !slices.Contains(db.parents[mimeType], parent) {
+				db.parents[mimeType] = append(db.parents[mimeType], parent)
+			}
+		})
+	}
+	return db
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *mimeDB) addGlob(glob mimeGlob) {
+	const wildcards = "*?["
+	switch {
+	case !strings.ContainsAny(glob.pattern, wildcards):
+		p.literals[glob.pattern] = append(p.literals[glob.pattern], glob)
+	case glob.pattern[0] == '*' &&
+		!strings.ContainsAny(glob.pattern[1:], wildcards):
+		suffix := glob.pattern[1:]
+		p.suffixes[suffix] = append(p.suffixes[suffix], glob)
+	default:
+		p.globs = append(p.globs, glob)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func bestGlob(globs map[string][]mimeGlob, key string) (mimeGlob, bool) {
+	var best mimeGlob
+	found := false
+	for _, k := range slices.Compact([]string{key, strings.ToLower(key)}) {
+		for _, glob := range globs[k] {
+			if glob.sensitive && k != key {
+				continue
+			}
+			if !found || glob.weight > best.weight {
+				best, found = glob, true
+			}
+		}
+	}
+	return best, found
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *mimeDB) typeOf(path string) string {
+	name := filepath.Base(path)
+	if glob, ok := bestGlob(p.literals, name); ok {
+		return glob.mimeType
+	}
+	for i := range name {
+		if glob, ok := bestGlob(p.suffixes, name[i:]); ok {
+			return glob.mimeType
+		}
+	}
+
+	var best *mimeGlob
+	lower := strings.ToLower(name)
+	for i, glob := range p.globs {
+		target := lower
+		if glob.sensitive {
+			target = name
+		}
+		if ok, _ := filepath.Match(glob.pattern, target); ok &&
+			(best == nil || glob.weight > best.weight) {
+			best = &p.globs[i]
+		}
+	}
+	if best != nil {
+		return best.mimeType
+	}
+	return sniffMimeType(path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func sniffMimeType(path string) string {
+	data, err := readHead(path, mimeSniffSize)
+	if err != nil || isBinary(data) {
+		return mimeBinary
+	}
+	return mimeText
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func readHead(path string, size int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, size)
+	n, err := io.ReadFull(f, data)
+	if err != nil &&
+		!errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func isBinary(data []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *mimeDB) unalias(mimeType string) string {
+	if canonical, ok := p.aliases[mimeType]; ok {
+		return canonical
+	}
+	return mimeType
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *mimeDB) lineage(mimeType string) []string {
+	// NOTE: Every text type is a text/plain, the catch-all octet stream is
+	// left out as it would list binary editors for every file.
+	types := []string{p.unalias(mimeType)}
+	for i := 0; i < len(types); i++ {
+		parents := p.parents[types[i]]
+		if strings.HasPrefix(types[i], "text/") {
+			parents = append(slices.Clone(parents), mimeText)
+		}
+		for _, parent := range parents {
+			if parent = p.unalias(parent); !slices.Contains(types, parent) {
+				types = append(types, parent)
+			}
+		}
+	}
+	return types
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func mimeAppsFiles(desktops []string) []string {
+	dirs := append(configDirs(), desktop.DataDirs()...)
+
+	var files []string
+	for _, dir := range dirs {
+		for _, name := range desktops {
+			files = append(files, filepath.Join(
+				dir,
+				strings.ToLower(name)+"-mimeapps.list"))
+		}
+		files = append(files, filepath.Join(dir, "mimeapps.list"))
+	}
+	return files
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadMimeApps(files []string) *mimeApps {
+	apps := &mimeApps{
+		defaults: make(map[string][]string),
+		added:    make(map[string][]string),
+		removed:  make(map[string][]string),
+	}
+	for _, file := range files {
+		groups := make(map[string]map[string][]string)
+		var group string
+		readLines(file, func(line string) {
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				group = line[1 : len(line)-1]
+				return
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return
+			}
+			if _, ok := groups[group]; !ok {
+				groups[group] = make(map[string][]string)
+			}
+			key = strings.TrimSpace(key)
+			groups[group][key] = desktopGroup{"": value}.list("")
+		})
+
+		for mimeType, ids := range groups[mimeDefaultGroup] {
+			apps.defaults[mimeType] = append(apps.defaults[mimeType], ids...)
+		}
+		for mimeType, ids := range groups[mimeAddedGroup] {
+			for _, id := range ids {
+				if !slices.Contains(apps.removed[mimeType], id) &&
+					!slices.Contains(apps.added[mimeType], id) {
+					apps.added[mimeType] = append(apps.added[mimeType], id)
+				}
+			}
+		}
+		for mimeType, ids := range groups[mimeRemovedGroup] {
+			apps.removed[mimeType] = append(apps.removed[mimeType], ids...)
+		}
+	}
+	return apps
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *mimeApps) handlers(loader *appLoader, types []string) []appHandler {
+	var handlers []appHandler
+	add := func(handler appHandler) {
+		if !slices.ContainsFunc(handlers, func(h appHandler) bool {
+			return h.id == handler.id
+		}) {
+			handlers = append(handlers, handler)
+		}
+	}
+	for _, mimeType := range types {
+		for _, id := range p.defaults[mimeType] {
+			if handler, ok := loader.lookupApp(id); ok {
+				add(handler)
+				break
+			}
+		}
+		for _, id := range p.added[mimeType] {
+			if handler, ok := loader.lookupApp(id); ok {
+				add(handler)
+			}
+		}
+		for _, handler := range loader.lookupHandlers(mimeType) {
+			if !slices.Contains(p.removed[mimeType], handler.id) {
+				add(handler)
+			}
+		}
+	}
+	return handlers
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,135 @@
+package dsearch
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestMimeDB(t *testing.T) {
+	user, system := t.TempDir(), t.TempDir()
+	writeTree(t, user, map[string]string{
+		"mime/globs2": `# user overrides
+50:text/x-log:__NOGLOBS__
+80:text/x-notes:*.log
+`,
+	})
+	writeTree(t, system, map[string]string{
+		"mime/globs2": `50:text/plain:*.txt
+50:text/x-log:*.log
+50:application/gzip:*.gz
+50:application/x-compressed-tar:*.tar.gz
+50:text/x-makefile:makefile
+50:text/x-makefile:Makefile:cs
+60:text/x-c++src:*.C:cs
+50:text/x-csrc:*.c
+40:text/x-readme:readme*
+55:text/x-readme-special:readme.*
+`,
+		"mime/aliases":    "text/x-c text/x-csrc\n",
+		"mime/subclasses": "text/x-csrc text/plain\ntext/x-notes text/x-log\n",
+	})
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"plain":  "some text\n",
+		"binary": "\x00\x01\x02",
+	})
+
+	db := loadMimeDB([]string{user, system})
+	tests := map[string]string{
+		"notes.TXT":                  "text/plain",
+		"archive.tar.gz":             "application/x-compressed-tar",
+		"file.gz":                    "application/gzip",
+		"server.log":                 "text/x-notes",
+		"MAKEFILE":                   "text/x-makefile",
+		"main.C":                     "text/x-c++src",
+		"main.c":                     "text/x-csrc",
+		"README.md":                  "text/x-readme-special",
+		"READMEFIRST":                "text/x-readme",
+		filepath.Join(dir, "plain"):  mimeText,
+		filepath.Join(dir, "binary"): mimeBinary,
+	}
+	for path, expected := range tests {
+		if result := db.typeOf(path); result != expected {
+			t.Errorf(`%s: Expected %s got %s`, path, expected, result)
+		}
+	}
+
+	expected := []string{"text/x-csrc", "text/plain"}
+	if result := db.lineage("text/x-c"); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+	expected = []string{"text/x-notes", "text/x-log", "text/plain"}
+	if result := db.lineage("text/x-notes"); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestMimeApps(t *testing.T) {
+	user := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", user)
+	t.Setenv("XDG_DATA_DIRS", t.TempDir())
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeDesktopFile(t, user, name+".desktop", `[Desktop Entry]
+Type=Application
+Name=`+name+`
+Exec=true %f
+MimeType=text/plain;
+`)
+	}
+	loader := newAppLoader(DefaultConfig().Launcher)
+	entryChan := make(chan *Entry)
+	go func() {
+		loader.load(context.Background(), entryChan)
+		close(entryChan)
+	}()
+	for range entryChan {
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"gnome-mimeapps.list": `[Default Applications]
+text/plain=missing.desktop;c.desktop;
+`,
+		"mimeapps.list": `[Default Applications]
+text/plain=a.desktop
+[Added Associations]
+text/plain=d.desktop;
+[Removed Associations]
+text/plain=b.desktop;
+`,
+		"system/mimeapps.list": `[Added Associations]
+text/plain=b.desktop;
+text/markdown=a.desktop;
+`,
+	})
+	apps := loadMimeApps([]string{
+		filepath.Join(dir, "gnome-mimeapps.list"),
+		filepath.Join(dir, "mimeapps.list"),
+		filepath.Join(dir, "system/mimeapps.list"),
+	})
+
+	ids := func(types ...string) []string {
+		var ids []string
+		for _, handler := range apps.handlers(loader, types) {
+			ids = append(ids, handler.id)
+		}
+		return ids
+	}
+	expected := []string{"c.desktop", "d.desktop", "a.desktop"}
+	if result := ids("text/plain"); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+	expected = []string{"a.desktop", "c.desktop", "d.desktop"}
+	if result := ids("text/markdown", "text/plain"); !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,158 @@
+package dsearch
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mnogu/go-calculator"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	previewLines = 200
+	previewTab   = "    "
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func buildPreview(node EntryNode, cfg PreviewConfig) string {
+	switch {
+	case isDirEntry(node):
+		return previewDir(node.Path())
+	case node.Source() == SourceFiles:
+		return previewFile(node.Path(), cfg.MaxBytes)
+	case node.Source() == SourceApplications:
+		return previewApp(node)
+	case node.Source() == SourceCalculator:
+		return previewCalculator(node.Value())
+	default:
+		return node.Subtitle()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func sanitize(line string) string {
+	line = strings.ReplaceAll(line, "\t", previewTab)
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func previewFile(path string, maxBytes int) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err.Error()
+	}
+	data, err := readHead(path, maxBytes)
+	if err != nil {
+		return err.Error()
+	}
+	if isBinary(data) {
+		return fmt.Sprintf(`Binary file, %d bytes`, info.Size())
+	}
+
+	lines := strings.Split(strings.ToValidUTF8(string(data), ""), "\n")
+	if len(lines) > previewLines {
+		lines = lines[:previewLines]
+	}
+	for i, line := range lines {
+		lines[i] = sanitize(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func previewDir(path string) string {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	var lines []string
+	for i, entry := range entries {
+		if i == previewLines {
+			lines = append(lines, fmt.Sprintf(`… %d more`, len(entries)-i))
+			break
+		}
+		name := sanitize(entry.Name())
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, name)
+	}
+	if len(lines) == 0 {
+		return "Empty directory"
+	}
+	return strings.Join(lines, "\n")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func previewApp(node EntryNode) string {
+	file, err := readDesktopFile(node.Path())
+	if err != nil {
+		return err.Error()
+	}
+	entry := file.entry()
+	exec := entry.Exec
+	if _, id, ok := strings.Cut(node.ID(), "#"); ok {
+		for _, action := range file.actions() {
+			if action.id == id {
+				exec = action.exec
+			}
+		}
+	}
+
+	var lines []string
+	if len(entry.Comment) > 0 {
+		lines = append(lines, sanitize(entry.Comment), "")
+	}
+	lines = append(lines,
+		"Exec: "+sanitize(exec),
+		"Path: "+sanitize(node.Path()))
+	return strings.Join(lines, "\n")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func previewCalculator(value string) string {
+	expr, _, _ := strings.Cut(value, " = ")
+	result, err := calculator.Calculate(expr)
+	if err != nil {
+		return err.Error()
+	}
+
+	lines := []string{
+		"Decimal: " + strconv.FormatFloat(result, 'f', -1, 64),
+		"Scientific: " + strconv.FormatFloat(result, 'e', -1, 64),
+	}
+	if result != math.Trunc(result) ||
+		math.Abs(result) >= math.MaxInt64 {
+		lines = append(lines, "Hex: "+strconv.FormatFloat(result, 'x', -1, 64))
+		return strings.Join(lines, "\n")
+	}
+
+	n := int64(result)
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return strings.Join(append(lines,
+		fmt.Sprintf(`Hex: %s0x%X`, sign, n),
+		fmt.Sprintf(`Octal: %s0o%o`, sign, n),
+		fmt.Sprintf(`Binary: %s0b%b`, sign, n)), "\n")
+}
+
+///////////////////////////////////////////////////////////////////////////////
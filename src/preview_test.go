@@ -0,0 +1,72 @@
+package dsearch
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestBuildPreview(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"notes.txt":   "first\tline\x1b[31m\nsecond line\nthird line\n",
+		"image.bin":   "\x89PNG\x00\x01",
+		"sub/a.txt":   "",
+		"sub/nested/": "",
+	})
+	user := t.TempDir()
+	writeDesktopFile(t, user, "editor.desktop", `[Desktop Entry]
+Type=Application
+Name=Editor
+Comment=Edit text files
+Exec=editor %F
+Actions=new;
+
+[Desktop Action new]
+Name=New Window
+Exec=editor --new
+`)
+	app := filepath.Join(user, "applications", "editor.desktop")
+
+	cfg := DefaultConfig().Preview
+	cfg.MaxBytes = 27
+	tests := []struct {
+		node     EntryNode
+		expected string
+	}{
+		{buildFileEntry(filepath.Join(dir, "notes.txt")), "first    line[31m\nsecond line"},
+		{buildFileEntry(filepath.Join(dir, "image.bin")), "Binary file, 6 bytes"},
+		{buildDirEntry(filepath.Join(dir, "sub")), "a.txt\nnested/"},
+		{
+			&Entry{id: "editor.desktop", source: SourceApplications, path: app},
+			fmt.Sprintf("Edit text files\n\nExec: editor %%F\nPath: %s", app),
+		},
+		{
+			&Entry{id: "editor.desktop#new", source: SourceApplications, path: app},
+			fmt.Sprintf("Edit text files\n\nExec: editor --new\nPath: %s", app),
+		},
+		{
+			loadCalculator("255"),
+			"Decimal: 255\nScientific: 2.55e+02\nHex: 0xFF\nOctal: 0o377\nBinary: 0b11111111",
+		},
+		{
+			loadCalculator("-1/8"),
+			"Decimal: -0.125\nScientific: -1.25e-01\nHex: -0x1p-03",
+		},
+	}
+	for _, test := range tests {
+		if result := buildPreview(test.node, cfg); result != test.expected {
+			t.Errorf(`%s: Expected %q got %q`, test.node.Value(), test.expected, result)
+		}
+	}
+
+	missing := buildFileEntry(filepath.Join(dir, "gone"))
+	if result := buildPreview(missing, cfg); !strings.Contains(result, "no such file") {
+		t.Errorf(`Expected a missing file got %q`, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
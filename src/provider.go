@@ -0,0 +1,538 @@
+package dsearch
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"code.rocketnine.space/tslocum/desktop"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type Provider interface {
+	Name() string
+	Load(ctx context.Context, sink chan<- *Entry)
+	Actions(node EntryNode) []Action
+}
+
+type QueryProvider interface {
+	Provider
+	Query(ctx context.Context, query Query) []*Entry
+}
+
+type RankedQueryProvider interface {
+	QueryProvider
+	ranked()
+}
+
+type OpenWithProvider interface {
+	Provider
+	OpenWith(path string) []Action
+}
+
+// NOTE: Watch is set up before the load and returns, the changes seen from
+// then on are sent until ctx is done.
+type WatchProvider interface {
+	Provider
+	Watch(ctx context.Context, updates chan<- EntryUpdate)
+}
+
+// NOTE: An update applies in order the replacement of every entry of the
+// source, the removal of the entries under dirs and of the removed IDs, then
+// adds the new entries.
+type EntryUpdate struct {
+	source  string
+	replace bool
+	dirs    []string
+	removed []string
+	added   []*Entry
+}
+
+type Query struct {
+	text   string
+	scoped bool
+}
+
+type Action struct {
+	name    string
+	execute func()
+	output  string
+	input   *actionInput
+}
+
+type actionInput struct {
+	prompt string
+	value  string
+	submit func(input string)
+}
+
+type ProviderFactory func(cfg *Config) Provider
+
+type providerRegistration struct {
+	name    string
+	factory ProviderFactory
+}
+
+type appProvider struct {
+	cfg    LauncherConfig
+	mutex  sync.Mutex
+	loader *appLoader
+	mime   *mimeDB
+	assoc  *mimeApps
+}
+
+type fileProvider struct {
+	cfg     LauncherConfig
+	roots   []*fileRoot
+	watcher *watcher
+}
+
+type calculatorProvider struct{}
+
+type commandProvider struct{}
+
+type webProvider struct {
+	engine string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+var providerRegistry = []providerRegistration{
+	{SourceCalculator, func(*Config) Provider { return &calculatorProvider{} }},
+	{SourceApplications, func(cfg *Config) Provider {
+		return &appProvider{cfg: cfg.Launcher}
+	}},
+	{SourceFiles, func(cfg *Config) Provider {
+		return &fileProvider{
+			cfg:   cfg.Launcher,
+			roots: newFileRoots(cfg.Files),
+		}
+	}},
+	{SourceCommand, func(*Config) Provider { return &commandProvider{} }},
+	{SourceWeb, func(cfg *Config) Provider {
+		return &webProvider{engine: cfg.Search.WebSearch}
+	}},
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func RegisterProvider(name string, factory ProviderFactory) {
+	i := slices.IndexFunc(providerRegistry, func(r providerRegistration) bool {
+		return r.name == name
+	})
+	if i >= 0 {
+		providerRegistry[i].factory = factory
+		return
+	}
+	providerRegistry = append(
+		providerRegistry,
+		providerRegistration{name: name, factory: factory})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func NewProviders(cfg *Config) []Provider {
+	registry := slices.Clone(providerRegistry)
+	for _, script := range cfg.Scripts {
+		registry = append(registry, providerRegistration{
+			name: script.Name,
+			factory: func(*Config) Provider {
+				if script.Protocol == ScriptRofi {
+					return newRofiProvider(script)
+				}
+				return newScriptProvider(script)
+			},
+		})
+	}
+
+	var providers []Provider
+	for _, registration := range registry {
+		if enabled, ok := cfg.Providers[registration.name]; ok && !enabled {
+			log.Printf(`Provider %s is disabled`, registration.name)
+			continue
+		}
+		providers = append(providers, registration.factory(cfg))
+	}
+	return providers
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func findProvider(providers []Provider, name string) Provider {
+	for _, provider := range providers {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func sendUpdate(
+	ctx context.Context,
+	updates chan<- EntryUpdate,
+	update EntryUpdate,
+) {
+	select {
+	case updates <- update:
+	case <-ctx.Done():
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func defaultActions(node EntryNode, name string) []Action {
+	return []Action{{name: name, execute: node.Execute}}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p Action) Name() string {
+	return p.name
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p Action) Output() string {
+	return p.output
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p Action) Execute() {
+	if p.execute != nil {
+		p.execute()
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) Name() string {
+	return SourceApplications
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) Load(ctx context.Context, sink chan<- *Entry) {
+	loader := newAppLoader(p.cfg)
+	db := loadMimeDB(dataDirs())
+	assoc := loadMimeApps(mimeAppsFiles(loader.desktops))
+	p.mutex.Lock()
+	p.loader, p.mime, p.assoc = loader, db, assoc
+	p.mutex.Unlock()
+	loader.load(ctx, sink)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) Actions(node EntryNode) []Action {
+	actions := defaultActions(node, "Launch")
+
+	p.mutex.Lock()
+	loader := p.loader
+	p.mutex.Unlock()
+	if loader == nil {
+		return actions
+	}
+	for _, entry := range loader.lookupActions(node.ID()) {
+		actions = append(actions, Action{
+			name:    entry.Value(),
+			execute: entry.Execute,
+		})
+	}
+	return actions
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) OpenWith(path string) []Action {
+	p.mutex.Lock()
+	loader, db, assoc := p.loader, p.mime, p.assoc
+	p.mutex.Unlock()
+	if loader == nil {
+		return nil
+	}
+
+	var actions []Action
+	for _, handler := range assoc.handlers(loader, db.lineage(db.typeOf(path))) {
+		actions = append(actions, Action{
+			name: "Open with " + handler.entry.Name,
+			execute: func() {
+				launchDesktopEntry(handler.path, handler.entry, p.cfg, path)
+			},
+		})
+	}
+	return actions
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) Watch(ctx context.Context, updates chan<- EntryUpdate) {
+	w, err := newWatcher()
+	if err != nil {
+		log.Printf(`Failed to watch applications, err: %v`, err)
+		return
+	}
+	for _, dir := range desktop.DataDirs() {
+		w.addTree(ctx, dir, true, nil)
+	}
+
+	// NOTE: A desktop file shadows the ones of the same ID in the lower
+	// precedence directories, so any change loads the applications again.
+	events := make(chan watchEvent)
+	go w.run(ctx, events)
+	go func() {
+		defer w.close()
+		collectEvents(ctx, events, func(batch []watchEvent) {
+			for _, event := range batch {
+				if event.dir && event.op == watchCreate {
+					w.addTree(ctx, event.path, true, nil)
+				} else if event.dir && event.op == watchRemove {
+					w.removeTree(event.path)
+				}
+			}
+
+			entryChan := make(chan *Entry)
+			go func() {
+				defer close(entryChan)
+				p.Load(ctx, entryChan)
+			}()
+			var entries []*Entry
+			for entry := range entryChan {
+				entries = append(entries, entry)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf(`Reload %d applications`, len(entries))
+			sendUpdate(ctx, updates, EntryUpdate{
+				source:  SourceApplications,
+				replace: true,
+				added:   entries,
+			})
+		})
+	}()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appProvider) Restore(record IndexRecord) *Entry {
+	_, action, _ := strings.Cut(record.ID, "#")
+	return &Entry{
+		id:       record.ID,
+		source:   SourceApplications,
+		name:     record.Name,
+		path:     record.Path,
+		keywords: record.Keywords,
+		execute: func() {
+			launchDesktopFile(record.Path, action, p.cfg)
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) Name() string {
+	return SourceFiles
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) Load(ctx context.Context, sink chan<- *Entry) {
+	loadFiles(ctx, sink, p.roots, p.watcher)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) Actions(node EntryNode) []Action {
+	path := node.Path()
+	if !isDirEntry(node) {
+		return append(defaultActions(node, "Open"), fileActions(path, false)...)
+	}
+	actions := append(
+		defaultActions(node, "Open Folder"),
+		Action{
+			name:    "Open in Terminal",
+			execute: func() { launchTerminal(path, p.cfg) },
+		},
+		Action{name: "Print Path", output: path})
+	return append(actions, fileActions(path, true)...)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) Watch(ctx context.Context, updates chan<- EntryUpdate) {
+	w, err := newWatcher()
+	if err != nil {
+		log.Printf(`Failed to watch files, err: %v`, err)
+		return
+	}
+	p.watcher = w
+
+	events := make(chan watchEvent)
+	go w.run(ctx, events)
+	go func() {
+		defer w.close()
+		collectEvents(ctx, events, func(batch []watchEvent) {
+			sendUpdate(ctx, updates, p.update(ctx, w, batch))
+		})
+	}()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) update(
+	ctx context.Context,
+	w *watcher,
+	batch []watchEvent,
+) EntryUpdate {
+	update := EntryUpdate{source: SourceFiles}
+	files := make(map[string]bool)
+	for _, event := range batch {
+		switch {
+		case event.op == watchOverflow:
+			entryChan := make(chan *Entry)
+			go func() {
+				defer close(entryChan)
+				p.Load(ctx, entryChan)
+			}()
+			update = EntryUpdate{source: SourceFiles, replace: true}
+			for entry := range entryChan {
+				update.added = append(update.added, entry)
+			}
+			clear(files)
+		case event.dir && event.op == watchCreate:
+			root := findRoot(p.roots, event.path)
+			if root == nil || root.skip(event.path, true) {
+				continue
+			}
+			root.walk(ctx, event.path, func(path string, dir bool) {
+				if dir {
+					w.watch(path)
+					files[path+"/"] = true
+				} else {
+					files[path] = true
+				}
+			})
+		case event.dir && event.op == watchRemove:
+			w.removeTree(event.path)
+			update.dirs = append(update.dirs, event.path)
+			for id := range files {
+				if inDirs(id, []string{event.path}) {
+					delete(files, id)
+				}
+			}
+			files[event.path+"/"] = false
+		case event.op == watchCreate:
+			root := findRoot(p.roots, event.path)
+			if root != nil && !root.skip(event.path, false) {
+				files[event.path] = true
+			}
+		case event.op == watchRemove:
+			files[event.path] = false
+		}
+	}
+
+	for id, exists := range files {
+		switch {
+		case !exists:
+			update.removed = append(update.removed, id)
+		case strings.HasSuffix(id, "/"):
+			entry := buildDirEntry(strings.TrimSuffix(id, "/"))
+			update.added = append(update.added, entry)
+		default:
+			update.added = append(update.added, buildFileEntry(id))
+		}
+	}
+	return update
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *fileProvider) Restore(record IndexRecord) *Entry {
+	if strings.HasSuffix(record.ID, "/") {
+		return buildDirEntry(record.Path)
+	}
+	return buildFileEntry(record.Path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *calculatorProvider) Name() string {
+	return SourceCalculator
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *calculatorProvider) Load(context.Context, chan<- *Entry) {}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *calculatorProvider) Query(_ context.Context, query Query) []*Entry {
+	if entry := loadCalculator(query.text); entry != nil {
+		return []*Entry{entry}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *calculatorProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Select")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *commandProvider) Name() string {
+	return SourceCommand
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *commandProvider) Load(context.Context, chan<- *Entry) {}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *commandProvider) Query(_ context.Context, query Query) []*Entry {
+	if !query.scoped || len(query.text) == 0 {
+		return nil
+	}
+	return []*Entry{loadCommand(query.text)}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *commandProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Run")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *webProvider) Name() string {
+	return SourceWeb
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *webProvider) Load(context.Context, chan<- *Entry) {}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *webProvider) Query(_ context.Context, query Query) []*Entry {
+	if !query.scoped || len(query.text) == 0 {
+		return nil
+	}
+	return []*Entry{loadWebSearch(query.text, p.engine)}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *webProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Search")
+}
+
+///////////////////////////////////////////////////////////////////////////////
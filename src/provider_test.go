@@ -0,0 +1,264 @@
+package dsearch
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type stubProvider struct{ entries []string }
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) Load(_ context.Context, sink chan<- *Entry) {
+	for _, name := range p.entries {
+		sink <- &Entry{name: name, source: "stub"}
+	}
+}
+
+func (p *stubProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Stub")
+}
+
+type openWithStub struct {
+	stubProvider
+	opened []string
+}
+
+func (p *openWithStub) OpenWith(path string) []Action {
+	return []Action{{
+		name:    "Open with Stub",
+		execute: func() { p.opened = append(p.opened, path) },
+	}}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func providerNames(providers []Provider) []string {
+	var names []string
+	for _, provider := range providers {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestNewProviders(t *testing.T) {
+	registry := slices.Clone(providerRegistry)
+	t.Cleanup(func() { providerRegistry = registry })
+
+	cfg := DefaultConfig()
+	expected := []string{
+		SourceCalculator,
+		SourceApplications,
+		SourceFiles,
+		SourceCommand,
+		SourceWeb,
+	}
+	result := providerNames(NewProviders(cfg))
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	RegisterProvider("stub", func(*Config) Provider { return &stubProvider{} })
+	cfg.Providers = map[string]bool{SourceFiles: false, SourceWeb: true}
+	expected = []string{
+		SourceCalculator,
+		SourceApplications,
+		SourceCommand,
+		SourceWeb,
+		"stub",
+	}
+	result = providerNames(NewProviders(cfg))
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestProviderActions(t *testing.T) {
+	user := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", user)
+	t.Setenv("XDG_DATA_DIRS", t.TempDir())
+	writeDesktopFile(t, user, "editor.desktop", `[Desktop Entry]
+Type=Application
+Name=Editor
+Exec=true %F
+MimeType=text/plain;text/markdown;
+Actions=new;
+
+[Desktop Action new]
+Name=New Window
+Exec=true --new
+`)
+
+	writeDesktopFile(t, user, "viewer.desktop", `[Desktop Entry]
+Type=Application
+Name=Viewer
+Exec=true %f
+NoDisplay=true
+MimeType=image/png;
+`)
+	writeTree(t, user, map[string]string{
+		"mime/globs2":     "50:text/plain:*.txt\n50:text/markdown:*.md\n",
+		"mime/subclasses": "text/markdown text/plain\n",
+	})
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("XDG_CONFIG_DIRS", t.TempDir())
+	writeTree(t, config, map[string]string{
+		"mimeapps.list": "[Default Applications]\ntext/plain=viewer.desktop\n",
+	})
+
+	stub := &stubProvider{entries: []string{"stub entry"}}
+	providers := []Provider{
+		&appProvider{cfg: DefaultConfig().Launcher},
+		&fileProvider{},
+		stub,
+	}
+	m := NewEntryManager(nil, dummyCfg, nil, providers...)
+	var loaders []func(chan *Entry)
+	for _, provider := range providers {
+		loaders = append(loaders, func(c chan *Entry) {
+			provider.Load(context.Background(), c)
+		})
+	}
+	m.LoadEntries(loaders...)
+
+	actions := func(query string) []string {
+		var names []string
+		for _, node := range m.FilterEntry(query) {
+			for _, action := range m.Actions(node) {
+				names = append(names, action.Name())
+			}
+		}
+		return names
+	}
+	result := actions("stub")
+	expected := []string{"Stub"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	result = actions("editor")
+	expected = []string{"Launch", "Editor: New Window", "Launch"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	openWith := func(path string) []string {
+		var names []string
+		for _, action := range m.Actions(buildFileEntry(path))[:4] {
+			names = append(names, action.Name())
+		}
+		return names
+	}
+	result = openWith("/tmp/notes.txt")
+	expected = []string{
+		"Open",
+		"Open with Viewer",
+		"Open with Editor",
+		"Reveal in File Manager",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	result = openWith("/tmp/README.MD")
+	expected = []string{
+		"Open",
+		"Open with Editor",
+		"Open with Viewer",
+		"Reveal in File Manager",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestOpenWithDefault(t *testing.T) {
+	handler := &openWithStub{}
+	m := NewEntryManager(nil, dummyCfg, nil, &fileProvider{}, handler)
+	opened := false
+	node := &Entry{
+		source:  SourceFiles,
+		name:    "/tmp/notes.txt",
+		path:    "/tmp/notes.txt",
+		execute: func() { opened = true },
+	}
+
+	var names []string
+	for _, action := range m.Actions(node)[:2] {
+		names = append(names, action.Name())
+	}
+	expected := []string{"Open", "Open with Stub"}
+	if !slices.Equal(expected, names) {
+		t.Errorf(`Expected %v got %v`, expected, names)
+	}
+	m.Execute(node)
+	if !opened || len(handler.opened) > 0 {
+		t.Errorf(`Expected the default action got %v`, handler.opened)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFileProviderActions(t *testing.T) {
+	provider := &fileProvider{cfg: DefaultConfig().Launcher}
+	names := func(actions []Action) []string {
+		var names []string
+		for _, action := range actions {
+			names = append(names, action.Name())
+		}
+		return names
+	}
+
+	result := names(provider.Actions(buildFileEntry("/home/user/notes.md")))
+	expected := []string{
+		"Open",
+		"Reveal in File Manager",
+		"Copy Path",
+		"Copy Contents",
+		"Rename",
+		"Move to Trash",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+
+	entry := buildDirEntry("/home/user/project")
+	if !isDirEntry(entry) || entry.ID() != "/home/user/project/" {
+		t.Errorf(`Unexpected directory entry %+v`, entry)
+	}
+	actions := provider.Actions(entry)
+	result = names(actions)
+	expected = []string{
+		"Open Folder",
+		"Open in Terminal",
+		"Print Path",
+		"Reveal in File Manager",
+		"Copy Path",
+		"Rename",
+		"Move to Trash",
+	}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+	if output := actions[2].Output(); output != "/home/user/project" {
+		t.Errorf(`Expected /home/user/project got %s`, output)
+	}
+
+	record := newIndexRecord(entry)
+	if restored := provider.Restore(record); !sameEntry(entry, restored) ||
+		!isDirEntry(restored) {
+		t.Errorf(`Expected %+v got %+v`, entry, restored)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
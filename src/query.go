@@ -0,0 +1,234 @@
+package dsearch
+
+import (
+	"strings"
+
+	"github.com/junegunn/fzf/src/algo"
+	"github.com/junegunn/fzf/src/util"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type termKind int
+
+const (
+	termPlain  termKind = 0
+	termQuoted termKind = 1
+	termPrefix termKind = 2
+	termSuffix termKind = 3
+	termEqual  termKind = 4
+)
+
+type queryTerm struct {
+	kind    termKind
+	inverse bool
+	text    string
+}
+
+// NOTE: Terms of a group are alternatives joined by "|", all groups have to
+// match, as in fzf's extended search mode.
+type extendedQuery [][]queryTerm
+
+type extendedTerm struct {
+	pattern Pattern
+	inverse bool
+}
+
+type extendedPattern [][]extendedTerm
+
+///////////////////////////////////////////////////////////////////////////////
+
+func splitQuery(query string) []string {
+	var tokens []string
+	var sb strings.Builder
+	escaped := false
+	for _, r := range query {
+		switch {
+		case escaped:
+			if r != ' ' {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ' || r == '\t':
+			if sb.Len() > 0 {
+				tokens = append(tokens, sb.String())
+				sb.Reset()
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+	if sb.Len() > 0 {
+		tokens = append(tokens, sb.String())
+	}
+	return tokens
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseTerm(token string) queryTerm {
+	term := queryTerm{kind: termPlain, text: token}
+	if len(term.text) > 1 && strings.HasPrefix(term.text, "!") {
+		term.inverse = true
+		term.text = term.text[1:]
+	}
+
+	if len(term.text) > 1 && strings.HasSuffix(term.text, "$") &&
+		!strings.HasSuffix(term.text, `\$`) {
+		term.kind = termSuffix
+		term.text = term.text[:len(term.text)-1]
+	}
+
+	switch {
+	case strings.HasPrefix(term.text, "'"):
+		if term.kind == termPlain {
+			term.kind = termQuoted
+			term.text = term.text[1:]
+		}
+	case strings.HasPrefix(term.text, "^"):
+		if term.kind == termSuffix {
+			term.kind = termEqual
+		} else {
+			term.kind = termPrefix
+		}
+		term.text = term.text[1:]
+	}
+	term.text = strings.ReplaceAll(term.text, `\$`, "$")
+	return term
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseExtended(query string) extendedQuery {
+	var groups extendedQuery
+	joined := false
+	for _, token := range splitQuery(query) {
+		if token == "|" {
+			joined = len(groups) > 0
+			continue
+		}
+
+		term := parseTerm(token)
+		if len(term.text) == 0 {
+			continue
+		}
+		if joined {
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		} else {
+			groups = append(groups, []queryTerm{term})
+		}
+		joined = false
+	}
+	return groups
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p extendedQuery) hasOperators() bool {
+	for _, group := range p {
+		if len(group) > 1 {
+			return true
+		}
+		for _, term := range group {
+			if term.kind != termPlain || term.inverse {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func compileExtended(
+	query extendedQuery,
+	matcher Matcher,
+	cfg FzfConfig,
+) Pattern {
+	var groups extendedPattern
+	for _, group := range query {
+		var terms []extendedTerm
+		for _, term := range group {
+			terms = append(terms, extendedTerm{
+				pattern: compileTerm(term, matcher, cfg),
+				inverse: term.inverse,
+			})
+		}
+		groups = append(groups, terms)
+	}
+	return groups
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func compileTerm(term queryTerm, matcher Matcher, cfg FzfConfig) Pattern {
+	// NOTE: A quoted term flips the matching mode like fzf does, it is exact
+	// with the fuzzy matcher and fuzzy with the exact matcher. An inverse term
+	// is always exact.
+	var matchFn algo.Algo
+	switch term.kind {
+	case termPlain:
+		if !term.inverse {
+			return matcher.Compile(term.text)
+		}
+		matchFn = algo.ExactMatchNaive
+	case termQuoted:
+		matchFn = algo.ExactMatchNaive
+		if matcher.Name() == MatcherExact {
+			matchFn = algo.FuzzyMatchV2
+		}
+	case termPrefix:
+		matchFn = algo.PrefixMatch
+	case termSuffix:
+		matchFn = algo.SuffixMatch
+	case termEqual:
+		matchFn = algo.EqualMatch
+	}
+
+	text := term.text
+	if cfg.ignoreCase {
+		text = strings.ToLower(text)
+	}
+	return termPattern{newFzfTerm(matchFn, []rune(text), cfg.ignoreCase)}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p extendedPattern) Match(
+	text *util.Chars,
+	withPos bool,
+	slab *util.Slab,
+) (int, []int, bool) {
+	score := 0
+	var positions []int
+	for _, group := range p {
+		matched := false
+		for _, term := range group {
+			s, pos, ok := term.pattern.Match(text, withPos && !term.inverse, slab)
+			if term.inverse {
+				s, pos, ok = 0, nil, !ok
+			}
+			if ok {
+				score += s
+				positions = append(positions, pos...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, nil, false
+		}
+	}
+	if withPos {
+		positions = compactPositions(positions)
+	}
+	return score, positions, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,52 @@
+package dsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestParseExtended(t *testing.T) {
+	tests := []struct {
+		query     string
+		expected  extendedQuery
+		operators bool
+	}{
+		{"fire fox", extendedQuery{
+			{{kind: termPlain, text: "fire"}},
+			{{kind: termPlain, text: "fox"}},
+		}, false},
+		{"'fire ^lib txt$ ^vim$", extendedQuery{
+			{{kind: termQuoted, text: "fire"}},
+			{{kind: termPrefix, text: "lib"}},
+			{{kind: termSuffix, text: "txt"}},
+			{{kind: termEqual, text: "vim"}},
+		}, true},
+		{"!fire !^lib !txt$", extendedQuery{
+			{{kind: termPlain, inverse: true, text: "fire"}},
+			{{kind: termPrefix, inverse: true, text: "lib"}},
+			{{kind: termSuffix, inverse: true, text: "txt"}},
+		}, true},
+		{"fire | ^vim code", extendedQuery{
+			{{kind: termPlain, text: "fire"}, {kind: termPrefix, text: "vim"}},
+			{{kind: termPlain, text: "code"}},
+		}, true},
+		{`my\ file \$5 ! ^ |`, extendedQuery{
+			{{kind: termPlain, text: "my file"}},
+			{{kind: termPlain, text: "$5"}},
+			{{kind: termPlain, text: "!"}},
+		}, false},
+	}
+	for _, test := range tests {
+		result := parseExtended(test.query)
+		if !reflect.DeepEqual(test.expected, result) {
+			t.Errorf(`%q: Expected %v got %v`, test.query, test.expected, result)
+		}
+		if operators := result.hasOperators(); operators != test.operators {
+			t.Errorf(`%q: Expected %v got %v`, test.query, test.operators, operators)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
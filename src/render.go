@@ -0,0 +1,127 @@
+package dsearch
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const ellipsis = "…"
+
+var matchStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("2")).
+	Bold(true)
+
+var subtitleStyle = lipgloss.NewStyle().Faint(true)
+
+var scopeStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("4")).
+	Bold(true)
+
+var previewStyle = lipgloss.NewStyle().
+	Border(lipgloss.NormalBorder(), false, false, false, true).
+	BorderForeground(lipgloss.Color("8")).
+	PaddingLeft(1)
+
+type marker func(string) string
+
+///////////////////////////////////////////////////////////////////////////////
+
+func renderEntry(node EntryNode, width int) string {
+	var positions []int
+	if matched, ok := node.(interface{ Positions() []int }); ok {
+		positions = matched.Positions()
+	}
+	text := highlight(node.Value(), positions, width, func(s string) string {
+		return matchStyle.Render(s)
+	})
+
+	width -= uniseg.StringWidth(node.Value()) + 1
+	subtitle := node.Subtitle()
+	if len(subtitle) == 0 || width <= uniseg.StringWidth(ellipsis) {
+		return text
+	}
+	subtitle = highlight(subtitle, nil, width, func(s string) string { return s })
+	return text + " " + subtitleStyle.Render(subtitle)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func renderPreview(content string, width, height int) string {
+	height = max(height, 1)
+	lines := strings.Split(content, "\n")
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, line := range lines {
+		lines[i] = highlight(line, nil, width-2, func(s string) string { return s })
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return previewStyle.Render(strings.Join(lines, "\n"))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func renderScope(scope string) string {
+	if len(scope) == 0 {
+		return ""
+	}
+	return scopeStyle.Render("["+scope+"]") + " "
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func highlight(text string, positions []int, width int, mark marker) string {
+	// NOTE: Positions are rune offsets, they are applied per grapheme cluster
+	// so combining characters are never split from their base character.
+	if width > 0 && uniseg.StringWidth(text) > width {
+		width -= uniseg.StringWidth(ellipsis)
+	} else {
+		width = -1
+	}
+
+	var sb, span strings.Builder
+	spanMarked, offset, used := false, 0, 0
+	flush := func() {
+		if span.Len() == 0 {
+			return
+		}
+		if spanMarked {
+			sb.WriteString(mark(span.String()))
+		} else {
+			sb.WriteString(span.String())
+		}
+		span.Reset()
+	}
+
+	graphemes := uniseg.NewGraphemes(text)
+	for graphemes.Next() {
+		runes := graphemes.Runes()
+		if width >= 0 && used+graphemes.Width() > width {
+			flush()
+			sb.WriteString(ellipsis)
+			return sb.String()
+		}
+		used += graphemes.Width()
+
+		marked := slices.ContainsFunc(positions, func(pos int) bool {
+			return pos >= offset && pos < offset+len(runes)
+		})
+		if marked != spanMarked {
+			flush()
+			spanMarked = marked
+		}
+		span.WriteString(graphemes.Str())
+		offset += len(runes)
+	}
+	flush()
+	return sb.String()
+}
+
+///////////////////////////////////////////////////////////////////////////////
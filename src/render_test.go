@@ -0,0 +1,34 @@
+package dsearch
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestHighlight(t *testing.T) {
+	mark := func(s string) string { return "[" + s + "]" }
+	cases := []struct {
+		text      string
+		positions []int
+		width     int
+		expected  string
+	}{
+		{"firefox", []int{0, 1, 4}, 0, "[fi]re[f]ox"},
+		{"firefox", nil, 0, "firefox"},
+		{"firefox", []int{5, 6}, 5, "fire…"},
+		{"日本語テキスト", []int{1, 2}, 0, "日[本語]テキスト"},
+		{"日本語テキスト", []int{0, 3}, 7, "[日]本語…"},
+		{"cafe\u0301 noir", []int{4}, 0, "caf[e\u0301] noir"},
+		{"cafe\u0301 noir", []int{3, 6}, 0, "caf[e\u0301] [n]oir"},
+		{"cafe\u0301 noir", nil, 5, "cafe\u0301…"},
+	}
+	for _, c := range cases {
+		result := highlight(c.text, c.positions, c.width, mark)
+		if result != c.expected {
+			t.Errorf(`%s: Expected %q got %q`, c.text, c.expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,252 @@
+package dsearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type SelectProvider interface {
+	Provider
+	Select(ctx context.Context, node EntryNode) (entries []*Entry, done bool)
+}
+
+// NOTE: A rofi script is called without arguments to list its rows, and with
+// the selected row or the custom input to act on it. A row is its text
+// optionally followed by options, only display, meta, info and nonselectable
+// are honored:
+//
+//	text\0meta\x1fhidden words\x1finfo\x1fpayload
+//
+// Lines starting with \0 set options of the mode, only data and no-custom are
+// honored. Non selectable rows are skipped.
+type rofiProvider struct {
+	cfg      ScriptConfig
+	mutex    sync.Mutex
+	rows     map[string]rofiRow
+	data     string
+	noCustom bool
+}
+
+type rofiRow struct {
+	text    string
+	options map[string]string
+}
+
+const (
+	ScriptRofi = "rofi"
+
+	rofiSeparator  = "\x00"
+	rofiDelimiter  = "\x1f"
+	rofiInitial    = 0
+	rofiSelected   = 1
+	rofiCustom     = 2
+	rofiMeta       = "meta"
+	rofiInfo       = "info"
+	rofiDisplay    = "display"
+	rofiDisabled   = "nonselectable"
+	rofiData       = "data"
+	rofiNoCustom   = "no-custom"
+	rofiOptionTrue = "true"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newRofiProvider(cfg ScriptConfig) Provider {
+	return &rofiProvider{
+		cfg:  cfg,
+		rows: make(map[string]rofiRow),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) Name() string {
+	return p.cfg.Name
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseRofiOptions(fields []string) map[string]string {
+	options := make(map[string]string)
+	for i := 0; i+1 < len(fields); i += 2 {
+		options[fields[i]] = fields[i+1]
+	}
+	return options
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func parseRofi(output []byte) ([]rofiRow, map[string]string) {
+	var rows []rofiRow
+	mode := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		text, rest, _ := strings.Cut(line, rofiSeparator)
+		fields := strings.Split(rest, rofiDelimiter)
+		if len(text) == 0 {
+			for key, value := range parseRofiOptions(fields) {
+				mode[key] = value
+			}
+			continue
+		}
+		rows = append(rows, rofiRow{text: text, options: parseRofiOptions(fields)})
+	}
+	return rows, mode
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) run(
+	ctx context.Context,
+	retv int,
+	row rofiRow,
+) ([]rofiRow, error) {
+	if len(p.cfg.Command) == 0 {
+		return nil, errors.New("empty script command")
+	}
+
+	args := p.cfg.Command[1:len(p.cfg.Command):len(p.cfg.Command)]
+	if retv != rofiInitial {
+		args = append(args, row.text)
+	}
+	cmd := exec.CommandContext(ctx, p.cfg.Command[0], args...)
+
+	p.mutex.Lock()
+	env := append(os.Environ(), "ROFI_RETV="+strconv.Itoa(retv))
+	if info, ok := row.options[rofiInfo]; ok {
+		env = append(env, "ROFI_INFO="+info)
+	}
+	if len(p.data) > 0 {
+		env = append(env, "ROFI_DATA="+p.data)
+	}
+	p.mutex.Unlock()
+	cmd.Env = env
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Join(err, errors.New(stderr.String()))
+	}
+
+	rows, mode := parseRofi(output)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.data = mode[rofiData]
+	p.noCustom = mode[rofiNoCustom] == rofiOptionTrue
+	return rows, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) entries(rows []rofiRow) []*Entry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	selectable := make(map[string]rofiRow)
+	var entries []*Entry
+	for _, row := range rows {
+		if row.options[rofiDisabled] == rofiOptionTrue {
+			continue
+		}
+		id := p.cfg.Name + ":" + row.text
+		if _, ok := selectable[id]; ok {
+			continue
+		}
+		selectable[id] = row
+		entries = append(entries, p.entry(id, row))
+	}
+	if len(entries) > 0 {
+		p.rows = selectable
+	}
+	return entries
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) entry(id string, row rofiRow) *Entry {
+	name := row.text
+	if display, ok := row.options[rofiDisplay]; ok && len(display) > 0 {
+		name = display
+	}
+	var keywords []string
+	if meta := row.options[rofiMeta]; len(meta) > 0 {
+		keywords = []string{meta}
+	}
+	return &Entry{
+		id:       id,
+		source:   p.cfg.Name,
+		name:     name,
+		keywords: keywords,
+		execute: func() {
+			if _, err := p.run(context.Background(), rofiSelected, row); err != nil {
+				log.Printf(`Failed to run %s, err: %v`, p.cfg.Name, err)
+			}
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) Load(ctx context.Context, sink chan<- *Entry) {
+	rows, err := p.run(ctx, rofiInitial, rofiRow{})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf(`Failed to list %s, err: %v`, p.cfg.Name, err)
+		}
+		return
+	}
+	for _, entry := range p.entries(rows) {
+		sink <- entry
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) Select(
+	ctx context.Context,
+	node EntryNode,
+) ([]*Entry, bool) {
+	p.mutex.Lock()
+	row, ok := p.rows[node.ID()]
+	noCustom := p.noCustom
+	p.mutex.Unlock()
+
+	retv := rofiSelected
+	if !ok {
+		if noCustom {
+			return nil, false
+		}
+		retv = rofiCustom
+		row = rofiRow{text: node.Value()}
+	}
+
+	rows, err := p.run(ctx, retv, row)
+	if err != nil {
+		log.Printf(`Failed to run %s, err: %v`, p.cfg.Name, err)
+		return nil, true
+	}
+	if len(rows) == 0 {
+		return nil, true
+	}
+	return p.entries(rows), false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *rofiProvider) Actions(node EntryNode) []Action {
+	return defaultActions(node, "Select")
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,158 @@
+package dsearch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const testRofiScript = `#!/bin/sh
+echo "$ROFI_RETV ${ROFI_INFO:-} ${ROFI_DATA:-} $*" >> "$OUT"
+case "$ROFI_RETV:$1" in
+0:)
+	printf '\0data\037start\n'
+	printf 'Files\0icon\037folder\037meta\037documents\037info\037/home\n'
+	printf 'Header\0nonselectable\037true\n'
+	echo 'Quit'
+	;;
+1:Files)
+	printf '\0data\037files\n'
+	echo 'notes.txt'
+	echo 'Back'
+	;;
+2:busy)
+	printf 'Busy\0nonselectable\037true\n'
+	;;
+2:*)
+	echo "Created $1"
+	;;
+esac
+`
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newTestRofi(t *testing.T) (*rofiProvider, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "menu.sh")
+	if err := os.WriteFile(path, []byte(testRofiScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	t.Setenv("OUT", out)
+	t.Setenv("ROFI_INFO", "")
+	t.Setenv("ROFI_DATA", "")
+	provider := newRofiProvider(ScriptConfig{
+		Name:     "menu",
+		Command:  []string{"sh", path},
+		Protocol: ScriptRofi,
+	})
+	return provider.(*rofiProvider), out
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestParseRofi(t *testing.T) {
+	output := "\x00prompt\x1fPick\nOne\x00info\x1fa\x1fmeta\x1fb c\nTwo\n\n"
+	rows, mode := parseRofi([]byte(output))
+	if mode["prompt"] != "Pick" {
+		t.Errorf(`Unexpected mode options %v`, mode)
+	}
+	if len(rows) != 2 || rows[0].text != "One" || rows[1].text != "Two" {
+		t.Fatalf(`Unexpected rows %v`, rows)
+	}
+	if rows[0].options[rofiInfo] != "a" || rows[0].options[rofiMeta] != "b c" {
+		t.Errorf(`Unexpected options %v`, rows[0].options)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestRofiProvider(t *testing.T) {
+	provider, out := newTestRofi(t)
+	entryChan := make(chan *Entry)
+	go func() {
+		provider.Load(context.Background(), entryChan)
+		close(entryChan)
+	}()
+	var entries []*Entry
+	for entry := range entryChan {
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf(`Expected 2 entries got %d`, len(entries))
+	}
+	entry := entries[0]
+	if entry.ID() != "menu:Files" || entry.Value() != "Files" ||
+		len(entry.Icon()) > 0 ||
+		!slices.Equal([]string{"documents"}, entry.Keywords()) {
+		t.Errorf(`Unexpected entry %+v`, entry)
+	}
+
+	entries, done := provider.Select(context.Background(), entry)
+	if done || len(entries) != 2 || entries[0].Value() != "notes.txt" {
+		t.Fatalf(`Unexpected reload %v %v`, entries, done)
+	}
+	if _, done := provider.Select(context.Background(), entries[1]); !done {
+		t.Errorf(`Expected the script to be done`)
+	}
+
+	custom := &Entry{source: "menu", name: "todo.txt"}
+	entries, done = provider.Select(context.Background(), custom)
+	if done || len(entries) != 1 || entries[0].Value() != "Created todo.txt" {
+		t.Errorf(`Unexpected custom input %v %v`, entries, done)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"0",
+		"1 /home start Files",
+		"1 files Back",
+		"2 todo.txt",
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := range calls {
+		calls[i] = strings.Join(strings.Fields(calls[i]), " ")
+	}
+	if !slices.Equal(expected, calls) {
+		t.Errorf(`Expected %q got %q`, expected, calls)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestRofiNonSelectable(t *testing.T) {
+	provider, _ := newTestRofi(t)
+	entryChan := make(chan *Entry)
+	go func() {
+		provider.Load(context.Background(), entryChan)
+		close(entryChan)
+	}()
+	var entries []*Entry
+	for entry := range entryChan {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf(`Expected 2 entries got %d`, len(entries))
+	}
+
+	custom := &Entry{source: "menu", name: "busy"}
+	if reloaded, done := provider.Select(context.Background(), custom); done ||
+		len(reloaded) != 0 {
+		t.Fatalf(`Unexpected reload %v %v`, reloaded, done)
+	}
+	reloaded, done := provider.Select(context.Background(), entries[0])
+	if done || len(reloaded) != 2 || reloaded[0].Value() != "notes.txt" {
+		t.Errorf(`Unexpected reload %v %v`, reloaded, done)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
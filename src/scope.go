@@ -0,0 +1,31 @@
+package dsearch
+
+import (
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p FzfConfig) scope(query string) (string, string) {
+	prefix := ""
+	for candidate, source := range p.prefixes {
+		if len(source) > 0 && len(candidate) > len(prefix) &&
+			strings.HasPrefix(query, candidate) {
+			prefix = candidate
+		}
+	}
+	if len(prefix) == 0 {
+		return "", query
+	}
+
+	// NOTE: "a | b" is an alternation in extended mode, not "| b" scoped to
+	// the applications.
+	rest := query[len(prefix):]
+	if tokens := splitQuery(rest); p.extended &&
+		len(tokens) > 0 && tokens[0] == "|" {
+		return "", query
+	}
+	return p.prefixes[prefix], rest
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,38 @@
+package dsearch
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestScope(t *testing.T) {
+	cfg := DefaultConfig().Search.fzfConfig()
+	cfg.prefixes[":"] = SourceCommand
+	cfg.prefixes["?"] = ""
+	tests := []struct {
+		query string
+		scope string
+		rest  string
+	}{
+		{"firefox", "", "firefox"},
+		{"a fire", SourceApplications, "fire"},
+		{"f notes", SourceFiles, "notes"},
+		{":ls", SourceCommand, "ls"},
+		{"=2*8", SourceCalculator, "2*8"},
+		{">ls -la", SourceCommand, "ls -la"},
+		{"?golang", "", "?golang"},
+		{"a | b", "", "a | b"},
+		{"a |b", SourceApplications, "|b"},
+		{"abc", "", "abc"},
+	}
+	for _, test := range tests {
+		scope, rest := cfg.scope(test.query)
+		if scope != test.scope || rest != test.rest {
+			t.Errorf(`%q: Expected (%q, %q) got (%q, %q)`,
+				test.query, test.scope, test.rest, scope, rest)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,271 @@
+package dsearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+// NOTE: A script is called with the method and its arguments, the same
+// request is written as a JSON line on its stdin:
+//
+//	script list                  {"method":"list"}
+//	script query <query>         {"method":"query","query":"..."}
+//	script run <id> <action>     {"method":"run","id":"...","action":"..."}
+//
+// list and query answer with one JSON item per line on stdout. Scripts
+// written for rofi's script mode are run with the rofi protocol instead.
+type ScriptConfig struct {
+	Name     string   `json:"name"`
+	Command  []string `json:"command"`
+	Dynamic  bool     `json:"dynamic"`
+	Protocol string   `json:"protocol"`
+}
+
+type scriptRequest struct {
+	Method string `json:"method"`
+	Query  string `json:"query,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+type scriptItem struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Subtitle string         `json:"subtitle"`
+	Icon     string         `json:"icon"`
+	Actions  []scriptAction `json:"actions"`
+}
+
+type scriptAction struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type scriptProvider struct {
+	cfg     ScriptConfig
+	mutex   sync.Mutex
+	actions map[string][]scriptAction
+}
+
+const (
+	scriptList    = "list"
+	scriptQuery   = "query"
+	scriptRun     = "run"
+	scriptDefault = "default"
+
+	scriptQueryTimeout = 2 * time.Second
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newScriptProvider(cfg ScriptConfig) Provider {
+	return &scriptProvider{
+		cfg:     cfg,
+		actions: make(map[string][]scriptAction),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) Name() string {
+	return p.cfg.Name
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) command(
+	ctx context.Context,
+	request scriptRequest,
+) (*exec.Cmd, error) {
+	if len(p.cfg.Command) == 0 {
+		return nil, errors.New("empty script command")
+	}
+
+	args := append(p.cfg.Command[1:len(p.cfg.Command):len(p.cfg.Command)],
+		request.Method)
+	switch request.Method {
+	case scriptQuery:
+		args = append(args, request.Query)
+	case scriptRun:
+		args = append(args, request.ID, request.Action)
+	}
+
+	return exec.CommandContext(ctx, p.cfg.Command[0], args...), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p scriptRequest) encode() []byte {
+	data, _ := json.Marshal(p)
+	return append(data, '\n')
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) read(
+	ctx context.Context,
+	request scriptRequest,
+	fn func(*Entry),
+) error {
+	cmd, err := p.command(ctx, request)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = bytes.NewReader(request.encode())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var item scriptItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			log.Printf(`Skip malformed item of %s, err: %v`, p.cfg.Name, err)
+			continue
+		}
+		if len(item.Title) == 0 {
+			continue
+		}
+		fn(p.entry(item))
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) entry(item scriptItem) *Entry {
+	if len(item.ID) == 0 {
+		item.ID = item.Title
+	}
+
+	p.mutex.Lock()
+	p.actions[item.ID] = item.Actions
+	p.mutex.Unlock()
+
+	action := scriptDefault
+	if len(item.Actions) > 0 {
+		action = item.Actions[0].ID
+	}
+	var keywords []string
+	if len(item.Subtitle) > 0 {
+		keywords = []string{item.Subtitle}
+	}
+	return &Entry{
+		id:       p.cfg.Name + ":" + item.ID,
+		source:   p.cfg.Name,
+		name:     item.Title,
+		subtitle: item.Subtitle,
+		icon:     item.Icon,
+		keywords: keywords,
+		execute: func() {
+			p.run(item.ID, action)
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) Load(ctx context.Context, sink chan<- *Entry) {
+	if err := p.read(ctx, scriptRequest{Method: scriptList}, func(e *Entry) {
+		sink <- e
+	}); err != nil && ctx.Err() == nil {
+		log.Printf(`Failed to list %s, err: %v`, p.cfg.Name, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) Query(ctx context.Context, query Query) []*Entry {
+	if !p.cfg.Dynamic || len(query.text) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, scriptQueryTimeout)
+	defer cancel()
+
+	var entries []*Entry
+	request := scriptRequest{Method: scriptQuery, Query: query.text}
+	if err := p.read(ctx, request, func(e *Entry) {
+		entries = append(entries, e)
+	}); err != nil && ctx.Err() == nil {
+		log.Printf(`Failed to query %s, err: %v`, p.cfg.Name, err)
+	}
+	return entries
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) ranked() {}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) run(id, action string) {
+	request := scriptRequest{Method: scriptRun, ID: id, Action: action}
+	cmd, err := p.command(context.Background(), request)
+	if err != nil {
+		log.Printf(`Failed to run %s, err: %v`, p.cfg.Name, err)
+		return
+	}
+
+	// NOTE: The script outlives dsearch, so the request is handed over
+	// through a pipe instead of being copied by a goroutine.
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		log.Printf(`Failed to run %s, err: %v`, p.cfg.Name, err)
+		return
+	}
+	defer reader.Close()
+	writer.Write(request.encode())
+	writer.Close()
+
+	cmd.Stdin = reader
+	if err := startDetached(cmd); err != nil {
+		log.Printf(`Failed to exec %s, err:%v`, cmd.String(), err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *scriptProvider) Actions(node EntryNode) []Action {
+	id := strings.TrimPrefix(node.ID(), p.cfg.Name+":")
+	p.mutex.Lock()
+	actions := p.actions[id]
+	p.mutex.Unlock()
+
+	if len(actions) == 0 {
+		return defaultActions(node, "Run")
+	}
+	var result []Action
+	for _, action := range actions {
+		result = append(result, Action{
+			name:    action.Name,
+			execute: func() { p.run(id, action.ID) },
+		})
+	}
+	return result
+}
+
+///////////////////////////////////////////////////////////////////////////////
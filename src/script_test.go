@@ -0,0 +1,150 @@
+package dsearch
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+const testScript = `#!/bin/sh
+read -r request
+case "$1" in
+list)
+	echo '{"id":"PROJ-1","title":"Fix login","subtitle":"In progress","actions":[{"id":"open","name":"Open"},{"id":"copy","name":"Copy key"}]}'
+	echo 'not json'
+	echo '{"id":"PROJ-2","title":"Write docs"}'
+	;;
+query)
+	echo "{\"id\":\"q\",\"title\":\"Search $2\"}"
+	;;
+run)
+	echo "$2 $3 $request" > "$OUT"
+	;;
+esac
+`
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newTestScript(t *testing.T, dynamic bool) (*scriptProvider, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tickets.sh")
+	if err := os.WriteFile(path, []byte(testScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	t.Setenv("OUT", out)
+	provider := newScriptProvider(ScriptConfig{
+		Name:    "tickets",
+		Command: []string{"sh", path},
+		Dynamic: dynamic,
+	})
+	return provider.(*scriptProvider), out
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestScriptProviderLoad(t *testing.T) {
+	provider, out := newTestScript(t, false)
+	entryChan := make(chan *Entry)
+	go func() {
+		provider.Load(context.Background(), entryChan)
+		close(entryChan)
+	}()
+	var entries []*Entry
+	for entry := range entryChan {
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf(`Expected 2 entries got %d`, len(entries))
+	}
+	entry := entries[0]
+	if entry.ID() != "tickets:PROJ-1" || entry.Source() != "tickets" ||
+		entry.Value() != "Fix login" || entry.Subtitle() != "In progress" {
+		t.Errorf(`Unexpected entry %+v`, entry)
+	}
+
+	var names []string
+	actions := provider.Actions(entry)
+	for _, action := range actions {
+		names = append(names, action.Name())
+	}
+	if expected := []string{"Open", "Copy key"}; !slices.Equal(expected, names) {
+		t.Errorf(`Expected %v got %v`, expected, names)
+	}
+
+	actions[1].Execute()
+	expected := `PROJ-1 copy {"method":"run","id":"PROJ-1","action":"copy"}`
+	for i := 0; i < 100; i++ {
+		if data, err := os.ReadFile(out); err == nil && len(data) > 0 {
+			if result := strings.TrimSpace(string(data)); result != expected {
+				t.Errorf(`Expected %s got %s`, expected, result)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf(`Action was not called back`)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestScriptProviderQuery(t *testing.T) {
+	provider, _ := newTestScript(t, false)
+	query := Query{text: "login"}
+	if entries := provider.Query(context.Background(), query); entries != nil {
+		t.Errorf(`Expected no entries got %v`, entries)
+	}
+
+	provider, _ = newTestScript(t, true)
+	entries := provider.Query(context.Background(), query)
+	if len(entries) != 1 || entries[0].Value() != "Search login" {
+		t.Errorf(`Unexpected entries %v`, entries)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestScriptProviderFilter(t *testing.T) {
+	provider, _ := newTestScript(t, true)
+	cfg := dummyCfg
+	cfg.prefixes = map[string]string{"t ": "tickets"}
+	m := NewEntryManager(nil, cfg, nil, provider)
+	m.LoadEntries(func(entryChan chan *Entry) {
+		entryChan <- &Entry{name: "search login page"}
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+		pinned   bool
+	}{
+		{
+			"search login",
+			[]string{"search login page", "Search search login"},
+			false,
+		},
+		{"t search login", []string{"Search search login"}, true},
+	}
+	for _, test := range tests {
+		nodes := m.FilterEntry(test.query)
+		if result := extract(nodes); !slices.Equal(test.expected, result) {
+			t.Errorf(`%q: Expected %v got %v`, test.query, test.expected, result)
+			continue
+		}
+		score := nodes[len(nodes)-1].(*MatchedEntry).Score()
+		if pinned := score == math.MaxInt32; pinned != test.pinned {
+			t.Errorf(`%q: Expected pinned %v got score %d`,
+				test.query, test.pinned, score)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
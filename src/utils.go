@@ -1,16 +1,18 @@
 package dsearch
 
 import (
-	"bufio"
+	"cmp"
+	"context"
 	"fmt"
 	"io/fs"
 	"log"
 	"math"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
-	"syscall"
+	"sync"
 
 	"code.rocketnine.space/tslocum/desktop"
 
@@ -31,7 +33,7 @@ func loadCalculator(expr string) *Entry {
 		return nil
 	}
 
-	entry := Entry{execute: func() {}}
+	entry := Entry{source: SourceCalculator, execute: func() {}}
 	if cal == math.Trunc(cal) {
 		entry.name = fmt.Sprintf(`%s = %d`, expr, int64(cal))
 	} else {
@@ -42,23 +44,115 @@ func loadCalculator(expr string) *Entry {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func loadApplications(entryChan chan *Entry) {
+func loadCommand(command string) *Entry {
+	return &Entry{
+		id:     SourceCommand + ":" + command,
+		source: SourceCommand,
+		name:   command,
+		execute: func() {
+			launch("sh", "-c", command)
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadWebSearch(query, engine string) *Entry {
+	target := strings.ReplaceAll(engine, "%s", url.QueryEscape(query))
+	return &Entry{
+		id:     SourceWeb + ":" + query,
+		source: SourceWeb,
+		name:   query,
+		execute: func() {
+			launch("xdg-open", target)
+		},
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type appLoader struct {
+	cfg      LauncherConfig
+	desktops []string
+	mutex    sync.Mutex
+	seen     map[string]struct{}
+	actions  map[string][]*Entry
+	apps     map[string]appHandler
+	handlers map[string][]appHandler
+}
+
+type appHandler struct {
+	id    string
+	path  string
+	entry *desktop.Entry
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newAppLoader(cfg LauncherConfig) *appLoader {
+	return &appLoader{
+		cfg:      cfg,
+		desktops: currentDesktops(),
+		seen:     make(map[string]struct{}),
+		actions:  make(map[string][]*Entry),
+		apps:     make(map[string]appHandler),
+		handlers: make(map[string][]appHandler),
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func loadApplications(
+	ctx context.Context,
+	entryChan chan<- *Entry,
+	cfg LauncherConfig,
+) {
+	newAppLoader(cfg).load(ctx, entryChan)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appLoader) load(ctx context.Context, entryChan chan<- *Entry) {
+	// NOTE: DataDirs is ordered by precedence, so walking the directories one
+	// after another lets the first desktop file ID shadow the later ones.
 	for _, dir := range desktop.DataDirs() {
-		walkDataDir(dir, entryChan)
+		if ctx.Err() != nil {
+			return
+		}
+		p.walkDataDir(ctx, dir, entryChan)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appLoader) claim(id string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.seen[id]; ok {
+		return false
 	}
+	p.seen[id] = struct{}{}
+	return true
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func walkDataDir(root string, entryChan chan *Entry) {
+func (p *appLoader) walkDataDir(
+	ctx context.Context,
+	root string,
+	entryChan chan<- *Entry,
+) {
 	fn := func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err != nil {
 			log.Printf(`Error during walking %s: %v\n`, path, err)
 			return nil // returning the error stops iteration
 		}
 
 		if !d.IsDir() {
-			parseDesktopFile(path, entryChan)
+			p.parseDesktopFile(root, path, entryChan)
 		}
 		return err
 	}
@@ -74,122 +168,184 @@ func walkDataDir(root string, entryChan chan *Entry) {
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func parseDesktopFile(path string, entryChan chan *Entry) {
-	parts := strings.Split(path, ".")
-	if len(parts) < 2 || parts[len(parts)-1] != "desktop" {
+func (p *appLoader) parseDesktopFile(
+	root, path string,
+	entryChan chan<- *Entry,
+) {
+	if filepath.Ext(path) != ".desktop" {
 		return
 	}
 
-	f, err := os.Open(path)
-	if err != nil {
-		log.Printf(`Failed to open file %s, err:%v`, path, err)
-	}
-	buf := make([]byte, 0, 64*1024)
-	reader := bufio.NewReader(f)
-	entry, err := desktop.Parse(reader, buf)
+	file, err := readDesktopFile(path)
 	if err != nil {
 		log.Printf(`Failed to parse file %s, err %v`, path, err)
 		return
 	}
-	if entry != nil && entry.Type == desktop.Application {
-		entryChan <- buildAppEntry(path, entry)
+	// NOTE: Hidden or filtered out files still claim their ID so they shadow
+	// the files of the same ID in the lower precedence directories.
+	id := desktopFileID(root, path)
+	if !p.claim(id) || !file.available(p.desktops) {
+		return
+	}
+	entry := file.entry()
+	if entry.Type != desktop.Application {
+		return
+	}
+	p.addHandler(appHandler{id: id, path: path, entry: entry}, file.mimeTypes())
+	if !file.visible(p.desktops) {
+		return
+	}
+
+	keywords := file.keywords()
+	entryChan <- buildAppEntry(id, path, entry, keywords, p.cfg)
+	for _, action := range file.actions() {
+		actionEntry := buildActionEntry(id, path, entry, action, keywords, p.cfg)
+		p.addAction(id, actionEntry)
+		entryChan <- actionEntry
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func buildAppEntry(path string, entry *desktop.Entry) *Entry {
-	action := func() {
-		cmd := exec.Command("gio", "launch", path)
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Foreground: false,
-			Setsid:     true,
-		}
-		if err := cmd.Start(); err != nil {
-			log.Fatalf(
-				`Failed to exec %s, err:%v`,
-				cmd.String(),
-				err)
-		}
-		cmd.Process.Release()
-	}
-	return &Entry{name: entry.Name, execute: action}
+func (p *appLoader) addAction(id string, entry *Entry) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.actions[id] = append(p.actions[id], entry)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func loadFiles(entryChan chan *Entry, hidden bool) bool {
-	root, err := os.UserHomeDir()
-	if err != nil {
-		log.Printf(`Failed to locate home directory`)
-		return false
+func (p *appLoader) lookupActions(id string) []*Entry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.actions[id]
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appLoader) addHandler(handler appHandler, mimeTypes []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.apps[handler.id] = handler
+	for _, mimeType := range mimeTypes {
+		p.handlers[mimeType] = append(p.handlers[mimeType], handler)
 	}
+}
 
-	fn := func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			log.Printf(`Error during walking %s: %v\n`, path, err)
-			return nil // returning the error stops iteration
-		}
+///////////////////////////////////////////////////////////////////////////////
 
-		relativePath := strings.Replace(path, root, "", 1)
-		if !d.IsDir() && (hidden || !isHiddenFile(relativePath)) {
-			entryChan <- buildFileEntry(path)
-		} else if d.IsDir() && !hidden && isHiddenDir(relativePath) {
-			return fastwalk.SkipDir
-		}
-		return err
+func (p *appLoader) lookupApp(id string) (appHandler, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	handler, ok := p.apps[id]
+	return handler, ok
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *appLoader) lookupHandlers(mimeType string) []appHandler {
+	p.mutex.Lock()
+	handlers := slices.Clone(p.handlers[mimeType])
+	p.mutex.Unlock()
+
+	slices.SortFunc(handlers, func(a, b appHandler) int {
+		return cmp.Or(
+			strings.Compare(a.entry.Name, b.entry.Name),
+			strings.Compare(a.id, b.id))
+	})
+	return handlers
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func buildAppEntry(
+	id, path string,
+	entry *desktop.Entry,
+	keywords []string,
+	cfg LauncherConfig,
+) *Entry {
+	action := func() {
+		launchDesktopEntry(path, entry, cfg)
+	}
+	return &Entry{
+		id:       id,
+		source:   SourceApplications,
+		name:     entry.Name,
+		path:     path,
+		keywords: keywords,
+		execute:  action,
 	}
+}
 
-	walkCfg := fastwalk.Config{
-		Follow:  false,
-		ToSlash: fastwalk.DefaultToSlash(),
+///////////////////////////////////////////////////////////////////////////////
+
+func buildActionEntry(
+	id, path string,
+	entry *desktop.Entry,
+	action desktopAction,
+	keywords []string,
+	cfg LauncherConfig,
+) *Entry {
+	execute := func() {
+		launchDesktopAction(path, entry, action, cfg)
+	}
+	return &Entry{
+		id:       id + "#" + action.id,
+		source:   SourceApplications,
+		name:     fmt.Sprintf(`%s: %s`, entry.Name, action.name),
+		path:     path,
+		keywords: keywords,
+		execute:  execute,
 	}
-	return fastwalk.Walk(
-		&walkCfg,
-		root,
-		fastwalk.IgnorePermissionErrors(fn)) == nil
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
 func buildFileEntry(path string) *Entry {
 	action := func() {
-		cmd := exec.Command("xdg-open", path)
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Foreground: false,
-			Setsid:     true,
-		}
-		if err := cmd.Start(); err != nil {
-			log.Fatalf(
-				`Failed to exec %s, err:%v`,
-				cmd.String(),
-				err)
-		}
-		cmd.Process.Release()
+		launch("xdg-open", path)
+	}
+	return &Entry{
+		id:      path,
+		source:  SourceFiles,
+		name:    path,
+		path:    path,
+		execute: action,
 	}
-	return &Entry{name: path, execute: action}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func isHiddenFile(path string) bool {
-	fileName := filepath.Base(path)
-	if len(fileName) > 1 && fileName[0] == '.' {
-		return true
+func buildDirEntry(path string) *Entry {
+	action := func() {
+		launch("xdg-open", path)
+	}
+	return &Entry{
+		id:      path + "/",
+		source:  SourceFiles,
+		name:    path + "/",
+		path:    path,
+		execute: action,
 	}
-	return false
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func isHiddenDir(path string) bool {
-	tokens := strings.Split(path, "/")
-	for _, token := range tokens {
-		if len(token) > 1 && token[0] == '.' {
-			return true
-		}
+func isDirEntry(node EntryNode) bool {
+	return node.Source() == SourceFiles && strings.HasSuffix(node.ID(), "/")
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); len(dir) > 0 && filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), fallback)
 	}
-	return false
+	return filepath.Join(home, fallback)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
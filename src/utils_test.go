@@ -0,0 +1,65 @@
+package dsearch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func writeDesktopFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, "applications", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestLoadApplications(t *testing.T) {
+	user, system := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_DATA_HOME", user)
+	t.Setenv("XDG_DATA_DIRS", system)
+	t.Setenv("XDG_CURRENT_DESKTOP", "sway:wlroots")
+
+	app := func(name string, extra string) string {
+		return "[Desktop Entry]\nType=Application\nExec=true\nName=" +
+			name + "\n" + extra
+	}
+	writeDesktopFile(t, user, "editor.desktop", app("User Editor", ""))
+	writeDesktopFile(t, system, "editor.desktop", app("System Editor", ""))
+	writeDesktopFile(t, user, "removed.desktop", app("Removed", "Hidden=true"))
+	writeDesktopFile(t, system, "removed.desktop", app("System Removed", ""))
+	writeDesktopFile(t, system, "helper.desktop", app("Helper", "NoDisplay=true"))
+	writeDesktopFile(t, system, "gnome.desktop", app("Gnome", "OnlyShowIn=GNOME;"))
+	writeDesktopFile(t, system, "sway.desktop", app("Sway", "OnlyShowIn=sway;"))
+	writeDesktopFile(t, system, "kde.desktop", app("Not Sway", "NotShowIn=sway;"))
+	writeDesktopFile(t, system, "missing.desktop",
+		app("Missing", "TryExec=dsearch-missing-binary"))
+	writeDesktopFile(t, system, "vendor/tool.desktop", app("Vendor Tool", ""))
+	writeDesktopFile(t, user, "vendor-tool.desktop", app("User Tool", ""))
+
+	entryChan := make(chan *Entry)
+	go func() {
+		loadApplications(context.Background(), entryChan, DefaultConfig().Launcher)
+		close(entryChan)
+	}()
+	var result []string
+	for entry := range entryChan {
+		result = append(result, entry.Value())
+	}
+	slices.Sort(result)
+
+	expected := []string{"Sway", "User Editor", "User Tool"}
+	if !slices.Equal(expected, result) {
+		t.Errorf(`Expected %v got %v`, expected, result)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
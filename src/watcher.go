@@ -0,0 +1,284 @@
+package dsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlievieth/fastwalk"
+	"golang.org/x/sys/unix"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+type watchOp int
+
+const (
+	watchCreate   watchOp = 0
+	watchRemove   watchOp = 1
+	watchWrite    watchOp = 2
+	watchOverflow watchOp = 3
+)
+
+type watchEvent struct {
+	op   watchOp
+	path string
+	dir  bool
+}
+
+type watcher struct {
+	fd      int
+	file    *os.File
+	mutex   sync.Mutex
+	watches map[int32]string
+	full    bool
+	closed  bool
+}
+
+const (
+	watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+	watchDelay    = 100 * time.Millisecond
+	watchMaxDelay = time.Second
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func newWatcher() (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: The descriptor is non blocking so reads go through the runtime
+	// poller and closing the file interrupts them.
+	return &watcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string),
+	}, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.file.Close()
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) add(dir string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return os.ErrClosed
+	}
+	if p.full {
+		return unix.ENOSPC
+	}
+
+	wd, err := unix.InotifyAddWatch(p.fd, dir, watchMask)
+	if errors.Is(err, unix.ENOSPC) {
+		p.full = true
+		log.Printf(`Reached the limit of inotify watches at %s`, dir)
+	}
+	if err != nil {
+		return err
+	}
+	p.watches[int32(wd)] = dir
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) watch(dir string) {
+	if err := p.add(dir); err != nil &&
+		!errors.Is(err, unix.ENOSPC) && !errors.Is(err, fs.ErrPermission) &&
+		!errors.Is(err, os.ErrClosed) {
+		log.Printf(`Failed to watch %s, err: %v`, dir, err)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) addTree(
+	ctx context.Context,
+	root string,
+	follow bool,
+	fn func(path string),
+) {
+	walkFn := func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err != nil {
+			log.Printf(`Error during walking %s: %v\n`, path, err)
+			return nil // returning the error stops iteration
+		}
+
+		if !d.IsDir() {
+			if fn != nil {
+				fn(path)
+			}
+			return nil
+		}
+		p.watch(path)
+		return nil
+	}
+
+	fastwalk.Walk(
+		&fastwalk.Config{
+			Follow:  follow,
+			ToSlash: fastwalk.DefaultToSlash(),
+		},
+		root,
+		fastwalk.IgnorePermissionErrors(walkFn))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) removeTree(root string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for wd, dir := range p.watches {
+		if dir == root || strings.HasPrefix(dir, root+"/") {
+			unix.InotifyRmWatch(p.fd, uint32(wd))
+			delete(p.watches, wd)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) run(ctx context.Context, events chan<- watchEvent) {
+	defer close(events)
+	stop := context.AfterFunc(ctx, func() { p.close() })
+	defer stop()
+
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := p.file.Read(buffer)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf(`Failed to read inotify events, err: %v`, err)
+			}
+			return
+		}
+		for _, event := range p.parse(buffer[:n]) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (p *watcher) parse(data []byte) []watchEvent {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var events []watchEvent
+	for len(data) >= unix.SizeofInotifyEvent {
+		var raw unix.InotifyEvent
+		binary.Read(
+			bytes.NewReader(data[:unix.SizeofInotifyEvent]),
+			binary.NativeEndian,
+			&raw)
+		end := unix.SizeofInotifyEvent + int(raw.Len)
+		if end > len(data) {
+			break
+		}
+		name := string(bytes.TrimRight(data[unix.SizeofInotifyEvent:end], "\x00"))
+		data = data[end:]
+
+		if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+			events = append(events, watchEvent{op: watchOverflow})
+			continue
+		}
+		dir, ok := p.watches[raw.Wd]
+		if !ok {
+			continue
+		}
+		if raw.Mask&unix.IN_IGNORED != 0 {
+			delete(p.watches, raw.Wd)
+			continue
+		}
+
+		event := watchEvent{
+			path: filepath.Join(dir, name),
+			dir:  raw.Mask&unix.IN_ISDIR != 0,
+		}
+		switch {
+		case raw.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+			event.op = watchCreate
+		case raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+			event.op = watchRemove
+		case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+			event.op = watchWrite
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func collectEvents(
+	ctx context.Context,
+	events <-chan watchEvent,
+	fn func([]watchEvent),
+) {
+	var batch []watchEvent
+	var deadline time.Time
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if len(batch) == 0 {
+				deadline = time.Now().Add(watchMaxDelay)
+			}
+			batch = append(batch, event)
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(min(watchDelay, time.Until(deadline)))
+		case <-timer.C:
+			if len(batch) == 0 {
+				continue
+			}
+			fn(batch)
+			batch = nil
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,215 @@
+package dsearch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+
+func nextEvent(t *testing.T, events <-chan watchEvent) watchEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal(`Timed out waiting for an event`)
+	}
+	return watchEvent{}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	w, err := newWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.addTree(ctx, root, false, nil)
+	events := make(chan watchEvent)
+	go w.run(ctx, events)
+
+	path := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expected := []watchEvent{
+		{op: watchCreate, path: path},
+		{op: watchWrite, path: path},
+	}
+	for _, e := range expected {
+		if event := nextEvent(t, events); event != e {
+			t.Errorf(`Expected %+v got %+v`, e, event)
+		}
+	}
+
+	renamed := filepath.Join(root, "todo.txt")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "docs")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	expected = []watchEvent{
+		{op: watchRemove, path: path},
+		{op: watchCreate, path: renamed},
+		{op: watchCreate, path: dir, dir: true},
+	}
+	for _, e := range expected {
+		if event := nextEvent(t, events); event != e {
+			t.Errorf(`Expected %+v got %+v`, e, event)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestFileProviderUpdate(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "docs")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "c.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := newWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+
+	path := func(name string) string { return filepath.Join(root, name) }
+	provider := &fileProvider{roots: []*fileRoot{
+		newFileRoot(RootConfig{Path: root, Exclude: []string{"*.log"}}),
+	}}
+	update := provider.update(context.Background(), w, []watchEvent{
+		{op: watchCreate, path: path("old/tmp.txt")},
+		{op: watchRemove, path: path("old")},
+		{op: watchRemove, path: path("old/tmp.txt")},
+		{op: watchRemove, path: path("gone"), dir: true},
+		{op: watchCreate, path: path("gone/c.txt")},
+		{op: watchCreate, path: path(".hidden")},
+		{op: watchCreate, path: "/outside/d.txt"},
+		{op: watchCreate, path: dir, dir: true},
+	})
+
+	var added []string
+	for _, entry := range update.added {
+		added = append(added, entry.ID())
+	}
+	slices.Sort(added)
+	expected := []string{
+		path("docs") + "/",
+		path("docs/a.txt"),
+		path("docs/sub") + "/",
+		path("docs/sub/b.txt"),
+		path("gone/c.txt"),
+	}
+	if !slices.Equal(expected, added) {
+		t.Errorf(`Expected %v got %v`, expected, added)
+	}
+	slices.Sort(update.removed)
+	removed := []string{path("gone") + "/", path("old"), path("old/tmp.txt")}
+	if !slices.Equal(removed, update.removed) ||
+		!slices.Equal([]string{path("gone")}, update.dirs) {
+		t.Errorf(`Unexpected update %+v`, update)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestWatchRemoveNewest(t *testing.T) {
+	root := t.TempDir()
+	path := func(name string) string { return filepath.Join(root, name) }
+	if err := os.WriteFile(path("a.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	providers := []Provider{&fileProvider{roots: []*fileRoot{
+		newFileRoot(RootConfig{Path: root}),
+	}}}
+	signal := make(SigRefresh)
+	m := NewEntryManager(signal, dummyCfg, nil, providers...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := watchProviders(ctx, providers)
+	loadProviders(ctx, m, providers)
+	m.FilterEntry(".txt")
+	go applyUpdates(ctx, m, updates)
+
+	results := make(chan []string, 1024)
+	go func() {
+		for msg := range signal {
+			result := extract(msg.nodes)
+			slices.Sort(result)
+			results <- result
+		}
+	}()
+	if err := os.WriteFile(path("b.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{path("a.txt"), path("b.txt")}
+	for result := []string(nil); !slices.Equal(expected, result); {
+		select {
+		case result = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatalf(`Expected %v got %v`, expected, result)
+		}
+	}
+
+	if err := os.Remove(path("b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{path("a.txt")}
+	for result := []string(nil); !slices.Equal(expected, result); {
+		select {
+		case result = <-results:
+		case <-time.After(2 * time.Second):
+			t.Fatalf(`Expected %v got %v`, expected, result)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func TestCollectEventsMaxDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan watchEvent)
+	batches := make(chan []watchEvent, 16)
+	go collectEvents(ctx, events, func(batch []watchEvent) { batches <- batch })
+
+	// NOTE: Events keep coming faster than watchDelay, the batch is still
+	// handed over after watchMaxDelay.
+	ticker := time.NewTicker(watchDelay / 4)
+	defer ticker.Stop()
+	deadline := time.After(2 * watchMaxDelay)
+	for {
+		select {
+		case batch := <-batches:
+			if len(batch) == 0 {
+				t.Errorf(`Expected a non empty batch`)
+			}
+			return
+		case <-ticker.C:
+			events <- watchEvent{op: watchWrite, path: "/tmp/a"}
+		case <-deadline:
+			t.Fatal(`Timed out waiting for a batch`)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////